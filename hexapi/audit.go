@@ -0,0 +1,277 @@
+// Collection audit trail: every change to a card count, where it came from and what message caused it
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEntry is one change to a card count. Field is "qty" or "eaqty".
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	UUID    string    `json:"uuid"`
+	Name    string    `json:"name"`
+	Field   string    `json:"field"`
+	Before  int       `json:"before"`
+	After   int       `json:"after"`
+	Source  string    `json:"source"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Where the changes being made right now are coming from ("CardsAdded", "draft pick", "cache load" ...)
+// and the message (or part of one) that caused them. Event handlers set these before changing counts.
+var auditSource = "unknown"
+var auditMessage string
+
+// While a batch is open (an Overwrite, loading the cache or a manual import) we hold on to what every
+// card was at the start and only record the net change for each card once the batch is done. A batch
+// can be for just one nature of card, the same way an Overwrite is.
+var auditBatch map[string][2]int
+var auditBatchMessages map[string]string
+var auditBatchNature string
+
+// The last counts the audit trail has for each card, so loading the cache doesn't record the
+// whole collection every time we start up
+var auditKnown = make(map[string][2]int)
+
+// Batches from these sources tell us the whole collection (or all of one nature), so we record the
+// difference from what the audit trail last said. A logout zeroes everything without recording it,
+// so an Overwrite that gives us back what we had records nothing.
+var auditAgainstKnown = map[string]bool{"cache load": true, "Overwrite": true}
+
+// Say where the next changes are coming from. The message can be a string or anything we can turn into JSON.
+func setAuditSource(source string, msg interface{}) {
+	auditSource = source
+	switch m := msg.(type) {
+	case nil:
+		auditMessage = ""
+	case string:
+		auditMessage = m
+	default:
+		blob, _ := json.Marshal(m)
+		auditMessage = string(blob)
+	}
+}
+
+// Record a change to a card count
+func auditQtyChange(uuid string, field string, before int, after int) {
+	if auditBatch != nil {
+		if auditMessage != "" {
+			auditBatchMessages[uuid] = auditMessage
+		}
+		return
+	}
+	if before == after {
+		return
+	}
+	writeAuditEntries([]AuditEntry{{Time: now(), UUID: uuid, Name: getCardNameFromUUID(uuid), Field: field, Before: before, After: after, Source: auditSource, Message: auditMessage}})
+}
+
+// Start a batch of changes to cards of a nature (or every card if the nature is blank)
+func beginAuditBatch(source string, msg interface{}, nature string) {
+	setAuditSource(source, msg)
+	auditBatch = make(map[string][2]int)
+	auditBatchMessages = make(map[string]string)
+	auditBatchNature = nature
+	for uuid, c := range cardCollection {
+		auditBatch[uuid] = [2]int{c.qty, c.eaqty}
+	}
+	// The message for the batch as a whole goes on cards that don't get a message of their own
+	auditBatchMessages[""] = auditMessage
+}
+
+// Close out a batch and record the net change for every card that ended up different. See
+// auditAgainstKnown for the batches we compare against what the audit trail last said instead.
+func endAuditBatch() {
+	if auditBatch == nil {
+		return
+	}
+	batch, messages := auditBatch, auditBatchMessages
+	auditBatch, auditBatchMessages = nil, nil
+	var entries []AuditEntry
+	for uuid, c := range cardCollection {
+		if auditBatchNature != "" && c.nature != auditBatchNature {
+			continue
+		}
+		before := batch[uuid]
+		if known, ok := auditKnown[uuid]; ok && auditAgainstKnown[auditSource] {
+			before = known
+		}
+		msg, ok := messages[uuid]
+		if !ok {
+			msg = messages[""]
+		}
+		for i, after := range [2]int{c.qty, c.eaqty} {
+			if before[i] != after {
+				entries = append(entries, AuditEntry{Time: now(), UUID: uuid, Name: c.name, Field: []string{"qty", "eaqty"}[i], Before: before[i], After: after, Source: auditSource, Message: msg})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Field < entries[j].Field
+	})
+	writeAuditEntries(entries)
+	setAuditSource("unknown", nil)
+}
+
+// Append entries to the audit file
+func writeAuditEntries(entries []AuditEntry) {
+	for _, e := range entries {
+		known := auditKnown[e.UUID]
+		if e.Field == "qty" {
+			known[0] = e.After
+		} else {
+			known[1] = e.After
+		}
+		auditKnown[e.UUID] = known
+	}
+	auditFile := Config["collection_audit_file"]
+	if auditFile == "" || len(entries) == 0 {
+		return
+	}
+	values := make([]interface{}, len(entries))
+	for i, e := range entries {
+		values[i] = e
+	}
+	appendJSONLines(auditFile, values...)
+}
+
+// Read the audit trail, keeping only the entries 'keep' likes
+func readAuditEntries(keep func(AuditEntry) bool) []AuditEntry {
+	var entries []AuditEntry
+	readJSONLines(Config["collection_audit_file"], func(line []byte) {
+		var e AuditEntry
+		if err := json.Unmarshal(line, &e); err == nil && keep(e) {
+			entries = append(entries, e)
+		}
+	})
+	return entries
+}
+
+// Pick up where the audit trail left off
+func readAuditLog() {
+	for _, e := range readAuditEntries(func(AuditEntry) bool { return true }) {
+		known := auditKnown[e.UUID]
+		if e.Field == "qty" {
+			known[0] = e.After
+		} else {
+			known[1] = e.After
+		}
+		auditKnown[e.UUID] = known
+	}
+}
+
+// Find a card by UUID or name (ignoring case if we have to). From the command line the collection
+// and price feed names aren't loaded, so we fall back on the card database.
+func findCardUUID(s string) string {
+	if _, ok := cardCollection[s]; ok {
+		return s
+	}
+	if uuid, ok := ntum[s]; ok {
+		return uuid
+	}
+	for uuid, c := range cardCollection {
+		if strings.EqualFold(c.name, s) {
+			return uuid
+		}
+	}
+	for uuid, name := range cardDB {
+		if strings.EqualFold(name, s) {
+			return uuid
+		}
+	}
+	return s
+}
+
+// Everything that's happened to a card's counts
+func cardHistoryReport(card string) string {
+	uuid := findCardUUID(card)
+	// Entries have the name the card had when they were written, which catches cards we can't look up
+	entries := readAuditEntries(func(e AuditEntry) bool { return e.UUID == uuid || strings.EqualFold(e.Name, card) })
+	if len(entries) == 0 {
+		return fmt.Sprintf("No changes recorded for '%v' in '%v'\n", card, Config["collection_audit_file"])
+	}
+	last := entries[len(entries)-1]
+	if uuid == card {
+		uuid = last.UUID
+	}
+	name := getCardNameFromUUID(uuid)
+	if name == uuid && last.Name != "" {
+		name = last.Name
+	}
+	s := fmt.Sprintf("History for '%v' (%v):\n", name, uuid)
+	for _, e := range entries {
+		s += fmt.Sprintf("\t%v %-5v %3d -> %-3d %-12v %v\n", e.Time.Format("2006-01-02 15:04:05"), e.Field, e.Before, e.After, e.Source, e.Message)
+	}
+	return s
+}
+
+// Set card counts by hand from a file of "card : qty : eaqty" lines. That's the collection cache
+// format, but the card can be a name as well as a UUID. Cards that aren't in the file are left
+// alone, and the changes go in the audit trail as a manual import.
+func importCardCounts(file string) int {
+	in, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("Could not open card counts '%v': %v\n", file, err)
+		return 1
+	}
+	defer in.Close()
+	re := regexp.MustCompile(`^(.*?)\s*:\s*(\d+)\s*:\s*(\d+)$`)
+	var problems []string
+	imported := 0
+	beginAuditBatch("manual import", file, "")
+	scanner := bufio.NewScanner(in)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			problems = append(problems, fmt.Sprintf("line %v: expected 'card : qty : eaqty'", lineNum))
+			continue
+		}
+		uuid := findCardUUID(m[1])
+		if _, ok := cardCollection[uuid]; !ok {
+			problems = append(problems, fmt.Sprintf("line %v: no card called '%v'", lineNum, m[1]))
+			continue
+		}
+		qty, _ := strconv.Atoi(m[2])
+		eaqty, _ := strconv.Atoi(m[3])
+		setCardCount(uuid, qty)
+		setEACardCount(uuid, eaqty)
+		imported++
+	}
+	endAuditBatch()
+	for _, p := range problems {
+		fmt.Printf("\t%v\n", p)
+	}
+	fmt.Printf("Set counts for %v cards from '%v'\n", imported, file)
+	if imported > 0 {
+		cacheCollection()
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func historyRequest(rw http.ResponseWriter, req *http.Request) {
+	card := req.URL.Query().Get("card")
+	fmt.Printf("Request for collection history of '%v' received.\n", card)
+	report := cardHistoryReport(card)
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
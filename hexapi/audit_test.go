@@ -0,0 +1,148 @@
+// Test cases for the collection audit trail
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectionAudit(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	Config["collection_audit_file"] = filepath.Join(t.TempDir(), "audit.jsonl")
+	Config["collection_file"] = filepath.Join(t.TempDir(), "collection.out")
+	auditKnown = make(map[string][2]int)
+	resetPickLedger()
+	savedSleep := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = savedSleep }()
+	for _, uuid := range uuids {
+		c := cardCollection[uuid]
+		c.qty, c.eaqty, c.nature = 0, 0, "Card"
+		cardCollection[uuid] = c
+	}
+
+	collectionOrInventoryEvent(testCollectionMessage("Update", uuids[2], 2))
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[2]))
+	collectionOrInventoryEvent(testCollectionMessage("Overwrite", uuids[2], 1))
+	cacheCollection()
+	readCollectionCache()
+
+	entries := readAuditEntries(func(e AuditEntry) bool { return e.UUID == uuids[2] })
+	want := []struct {
+		source string
+		before int
+		after  int
+	}{
+		{"CardsAdded", 0, 2},
+		{"draft pick", 2, 3},
+		{"Overwrite", 3, 1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %v audit entries (%+v) but we expected %v", len(entries), entries, len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Source != w.source || e.Before != w.before || e.After != w.after || e.Field != "qty" {
+			t.Errorf("audit entry %v is %v %v -> %v (%v) but we expected %v %v -> %v", i, e.Source, e.Before, e.After, e.Field, w.source, w.before, w.after)
+		}
+		if e.Message == "" {
+			t.Errorf("audit entry %v has no message", i)
+		}
+	}
+}
+
+func TestManualImportAndHistory(t *testing.T) {
+	uuids := testDraftSetup(t, 3)
+	Config["collection_audit_file"] = filepath.Join(t.TempDir(), "audit.jsonl")
+	Config["collection_file"] = filepath.Join(t.TempDir(), "collection.out")
+	auditKnown = make(map[string][2]int)
+	for _, uuid := range uuids {
+		c := cardCollection[uuid]
+		c.qty, c.eaqty, c.nature = 0, 0, "Card"
+		cardCollection[uuid] = c
+	}
+	counts := filepath.Join(t.TempDir(), "counts.txt")
+	ioutil.WriteFile(counts, []byte("# Counted by hand\ncard 0 : 3 : 1\n"+uuids[1]+" : 2 : 0\nNo Such Card : 1 : 0\n"), 0660)
+	if code := importCardCounts(counts); code != 1 {
+		t.Errorf("importCardCounts() with a card we don't know returned %v but we expected 1", code)
+	}
+	if c := cardCollection[uuids[0]]; c.qty != 3 || c.eaqty != 1 {
+		t.Errorf("Card 0 has %v (%v EA) after the import but we expected 3 (1 EA)", c.qty, c.eaqty)
+	}
+	entries := readAuditEntries(func(AuditEntry) bool { return true })
+	if len(entries) != 3 {
+		t.Fatalf("got %v audit entries (%+v) but we expected 3", len(entries), entries)
+	}
+	for _, e := range entries {
+		if e.Source != "manual import" || e.Message != counts {
+			t.Errorf("audit entry %+v should come from a manual import of %v", e, counts)
+		}
+	}
+
+	// From the command line all we have is the card database, and maybe not even that
+	savedCollection := cardCollection
+	cardCollection = make(map[string]Card)
+	defer func() { cardCollection = savedCollection }()
+	cardDB[uuids[0]] = "Card 0"
+	defer delete(cardDB, uuids[0])
+	for _, c := range []struct {
+		card string
+		want string
+	}{
+		{"card 0", "History for 'Card 0' (" + uuids[0] + "):\n"},
+		{"Card 1", "History for 'Card 1' (" + uuids[1] + "):\n"},
+		{"Card 2", "No changes recorded for 'Card 2'"},
+	} {
+		if report := cardHistoryReport(c.card); !strings.HasPrefix(report, c.want) {
+			t.Errorf("cardHistoryReport(%q) == %q but we expected it to start with %q", c.card, report, c.want)
+		}
+	}
+}
+
+// Logging out zeroes the collection until the Overwrites come in. If they give us back what we
+// had, nothing changed and the audit trail shouldn't say otherwise.
+func TestAuditLogoutAndOverwrite(t *testing.T) {
+	uuids := testDraftSetup(t, 6)
+	Config["collection_audit_file"] = filepath.Join(t.TempDir(), "audit.jsonl")
+	resetPickLedger()
+	overwriteBaseline = nil
+	updatesSinceOverwrite = make(map[string]int)
+	savedSleep := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = savedSleep }()
+	auditKnown = make(map[string][2]int)
+	cards := testCollectionMessage("Overwrite", uuids[0], 1)
+	items := testCollectionMessage("Overwrite", uuids[5], 3)
+	items["Message"] = "Inventory"
+	for i, uuid := range uuids {
+		c := cardCollection[uuid]
+		c.qty, c.eaqty, c.nature = i+1, 0, "Card"
+		if i == 5 {
+			c.qty, c.nature = 3, "Inventory"
+		}
+		cardCollection[uuid] = c
+		auditKnown[uuid] = [2]int{c.qty, 0}
+		if i > 0 && i < 5 {
+			card := map[string]interface{}{"Guid": map[string]interface{}{"m_Guid": uuid}, "Count": float64(i + 1), "Flags": ""}
+			cards["Complete"] = append(cards["Complete"].([]interface{}), card)
+		}
+	}
+
+	logoutEvent("")
+	collectionOrInventoryEvent(cards)
+	collectionOrInventoryEvent(items)
+
+	if entries := readAuditEntries(func(AuditEntry) bool { return true }); len(entries) != 0 {
+		t.Errorf("got %v audit entries for a logout and Overwrites that changed nothing: %+v", len(entries), entries)
+	}
+	for i, uuid := range uuids {
+		if want := auditKnown[uuid][0]; cardCollection[uuid].qty != want {
+			t.Errorf("card %v has %v after the Overwrites but we expected %v", i, cardCollection[uuid].qty, want)
+		}
+	}
+}
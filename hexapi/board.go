@@ -0,0 +1,207 @@
+// Board state: every card instance we've heard about in the current game and which zone it's in
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The zones we show, in the order we show them. The values are the 'Collection' values from CardUpdated.
+var boardZones = []struct {
+	location int
+	name     string
+}{
+	{4, "Champion"},
+	{1, "Deck"},
+	{2, "Hand"},
+	{8, "Play"},
+	{64, "Shards"},
+	{128, "Chain"},
+	{16, "Crypt"},
+	{32, "Void"},
+	{256, "Underground"},
+}
+
+// The board as it stood at the end of the last game, so we can still look at it once the next one starts
+var lastBoard string
+
+// gameMutex guards the game in progress: currentGame, lastBoard, the turns and reveals, the health
+// samples and when the game started. Game messages and the board, turns and reveals pages all come
+// in on the API server's goroutines, so everything that touches them locks.
+var gameMutex sync.Mutex
+
+// Where a card arriving in a zone most likely came from, most likely first. We only need this to
+// tell copies of a card apart when a CardUpdated message doesn't have an Id.
+var zoneSources = map[int][]int{
+	1:   {2},
+	2:   {1},
+	8:   {128},
+	16:  {128, 8, 2, 1},
+	32:  {128, 8, 16, 2, 1},
+	64:  {2},
+	128: {2, 1, 256},
+	256: {2},
+}
+
+// How we tell card instances apart. Hex gives every card in a game its own Id. If that's missing
+// all we have is the card itself, so we number the copies and go by where they are: a copy in a
+// zone the card could have come from has moved, a copy already in the zone has been updated, and
+// anything else is a copy we haven't seen yet. Copies that turn up in the same zone without moving
+// there, like a whole deck at the start of a game, still look like one card.
+func (p *Player) cardInstanceKey(f map[string]interface{}, location int) string {
+	if id, ok := f["Id"]; ok {
+		return fmt.Sprintf("%v", id)
+	}
+	card, _ := f["Name"].(string)
+	if guid, ok := f["Guid"].(map[string]interface{}); ok {
+		if uuid, ok := guid["m_Guid"].(string); ok && uuid != "" {
+			card = uuid
+		}
+	}
+	var copies []string
+	for n := 1; ; n++ {
+		key := fmt.Sprintf("%v#%v", card, n)
+		if _, ok := p.cards[key]; !ok {
+			break
+		}
+		copies = append(copies, key)
+	}
+	var sources []int
+	for zone := 1; zone <= location; zone <<= 1 {
+		if location&zone != 0 {
+			sources = append(sources, zoneSources[zone]...)
+		}
+	}
+	for _, from := range sources {
+		for _, key := range copies {
+			if l := p.cards[key].location; l&from != 0 && l&location == 0 {
+				return key
+			}
+		}
+	}
+	for _, key := range copies {
+		if p.cards[key].location == location {
+			return key
+		}
+	}
+	return fmt.Sprintf("%v#%v", card, len(copies)+1)
+}
+
+// Build a gameCard from a CardUpdated message
+func gameCardFromJSON(f map[string]interface{}, location int) gameCard {
+	gc := gameCard{
+		controller: floatToInt(f["Controller"]),
+		cost:       floatToInt(f["Cost"]),
+		atk:        floatToInt(f["Attack"]),
+		def:        floatToInt(f["Defense"]),
+		state:      floatToInt(f["State"]),
+		attrs:      floatToInt(f["Attributes"]),
+		location:   location,
+	}
+	gc.name, _ = f["Name"].(string)
+	if shards, ok := f["Shards"]; ok && shards != nil {
+		gc.shards = fmt.Sprintf("%v", shards)
+	}
+	if guid, ok := f["Guid"].(map[string]interface{}); ok {
+		gc.uuid, _ = guid["m_Guid"].(string)
+	}
+	return gc
+}
+
+// Record where a card is now. We hand back what it looked like before (and whether we'd seen it at all).
+func (p *Player) updateCard(key string, gc gameCard) (gameCard, bool) {
+	if p.cards == nil {
+		p.cards = make(map[string]gameCard)
+	}
+	old, ok := p.cards[key]
+	p.cards[key] = gc
+	return old, ok
+}
+
+// Every card a player has in a zone, sorted by name. Cards can be in more than one zone at once.
+func (p *Player) zone(location int) []gameCard {
+	var cards []gameCard
+	for _, gc := range p.cards {
+		if gc.location&location != 0 {
+			cards = append(cards, gc)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		if cards[i].name != cards[j].name {
+			return cards[i].name < cards[j].name
+		}
+		return cards[i].def < cards[j].def
+	})
+	return cards
+}
+
+func (gc gameCard) String() string {
+	s := gc.name
+	if gc.location&(8|4) != 0 {
+		s += fmt.Sprintf(" [%v/%v]", gc.atk, gc.def)
+	}
+	if keywords := attributeKeywords(gc.attrs); len(keywords) > 0 {
+		s += fmt.Sprintf(" {%v}", strings.Join(keywords, ", "))
+	}
+	if gc.state != 0 {
+		s += fmt.Sprintf(" (%v)", translateCardState(gc.state))
+	}
+	return s
+}
+
+// One player's side of the board
+func sprintPlayerBoard(p *Player) string {
+	s := fmt.Sprintf("%v: %v health, %v resources (Blood %v, Diamond %v, Ruby %v, Sapphire %v, Wild %v)\n",
+		p.name, p.champion.def, p.resources, p.blood, p.diamond, p.ruby, p.sapphire, p.wild)
+	for _, z := range boardZones {
+		cards := p.zone(z.location)
+		if len(cards) == 0 {
+			continue
+		}
+		// Nobody cares which cards are at the bottom of a deck, just how many we know about
+		if z.location == 1 {
+			s += fmt.Sprintf("\t%-12v %v cards\n", z.name+":", len(cards))
+			continue
+		}
+		names := make([]string, len(cards))
+		for i, gc := range cards {
+			names[i] = gc.String()
+		}
+		s += fmt.Sprintf("\t%-12v %v\n", z.name+":", strings.Join(names, ", "))
+	}
+	return s
+}
+
+// Both sides of the board as they stand right now. The caller holds gameMutex.
+func sprintBoard() string {
+	if currentGame.p1.id == 0 {
+		return "No game in progress\n"
+	}
+	s := "==========================          BOARD STATE          ==========================\n"
+	s += sprintPlayerBoard(&currentGame.p1)
+	s += sprintPlayerBoard(&currentGame.p2)
+	return s
+}
+
+// Hang on to the board from the game that just ended. The caller holds gameMutex.
+func saveLastBoard() {
+	if currentGame.p1.id != 0 && (len(currentGame.p1.cards) > 0 || len(currentGame.p2.cards) > 0) {
+		lastBoard = sprintBoard()
+	}
+}
+
+func boardRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print board state received.")
+	gameMutex.Lock()
+	report := sprintBoard()
+	if lastBoard != "" {
+		report += "Last game ended with:\n" + lastBoard
+	}
+	gameMutex.Unlock()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
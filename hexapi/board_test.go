@@ -0,0 +1,133 @@
+// Test cases for board state tracking
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testCardUpdatedMessage(id int, name string, controller int, collection int, atk int, def int) map[string]interface{} {
+	return map[string]interface{}{
+		"Message": "CardUpdated", "Id": float64(id), "Name": name, "Controller": float64(controller), "Collection": float64(collection),
+		"Attack": float64(atk), "Defense": float64(def), "Cost": float64(2), "State": float64(0), "Attributes": float64(0), "Shards": "Wild",
+	}
+}
+
+func TestBoardState(t *testing.T) {
+	Config = make(map[string]string)
+	resetGame()
+	cardUpdatedEvent(testCardUpdatedMessage(10, "Yeti Spy", 1, 2, 2, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(11, "Yeti Spy", 1, 2, 2, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(12, "Wild Shard", 1, 64, 0, 0))
+	cardUpdatedEvent(testCardUpdatedMessage(20, "Crocodile Hunter", 2, 8, 3, 3))
+	// One of the Yetis gets played
+	cardUpdatedEvent(testCardUpdatedMessage(10, "Yeti Spy", 1, 8, 2, 1))
+
+	for _, f := range []struct {
+		player   *Player
+		location int
+		want     int
+	}{
+		{&currentGame.p1, 2, 1},
+		{&currentGame.p1, 8, 1},
+		{&currentGame.p1, 64, 1},
+		{&currentGame.p2, 8, 1},
+		{&currentGame.p2, 2, 0},
+	} {
+		got := len(f.player.zone(f.location))
+		if got != f.want {
+			t.Errorf("%v has %v cards in %v but we expected %v", f.player.name, got, cardZoneFlags.sprint(f.location), f.want)
+		}
+	}
+	board := sprintBoard()
+	if !strings.Contains(board, "Play:        Yeti Spy [2/1]") || !strings.Contains(board, "Play:        Crocodile Hunter [3/3]") {
+		t.Errorf("sprintBoard() gave us:\n%v\nwhich is missing cards in play", board)
+	}
+
+	// The board should still be there once the game's over
+	gameEndedEvent(map[string]interface{}{"Winners": []interface{}{"p1"}, "Losers": []interface{}{"p2"}})
+	if !strings.Contains(lastBoard, "Yeti Spy [2/1]") {
+		t.Errorf("lastBoard after the game ended is:\n%v\nbut we expected it to have the Yeti in play", lastBoard)
+	}
+}
+
+func TestBoardStateWithoutIds(t *testing.T) {
+	Config = make(map[string]string)
+	resetGame()
+	send := func(name string, controller int, collection int) {
+		f := testCardUpdatedMessage(0, name, controller, collection, 2, 1)
+		delete(f, "Id")
+		cardUpdatedEvent(f)
+	}
+	// Hex hasn't told us the player ids yet, so the first controller we see becomes p1
+	send("Yeti Spy", 5, 2)
+	if currentGame.p1.id != 5 {
+		t.Fatalf("p1's id is %v after a card controlled by 5 but we expected 5", currentGame.p1.id)
+	}
+	// That Yeti gets played and a second one is drawn while it's on the chain. It goes into play, then
+	// gets exhausted.
+	send("Yeti Spy", 5, 128)
+	send("Yeti Spy", 5, 2)
+	send("Yeti Spy", 5, 8)
+	send("Yeti Spy", 5, 8)
+	// Two Burns, one after the other
+	send("Burn", 5, 128)
+	send("Burn", 5, 16)
+	send("Burn", 5, 128)
+	send("Burn", 5, 16)
+
+	for _, f := range []struct {
+		location int
+		want     int
+	}{
+		{2, 1},
+		{128, 0},
+		{8, 1},
+		{16, 2},
+	} {
+		if got := len(currentGame.p1.zone(f.location)); got != f.want {
+			t.Errorf("p1 has %v cards in %v but we expected %v", got, cardZoneFlags.sprint(f.location), f.want)
+		}
+	}
+}
+
+// Game messages and the pages that show the game come in on different goroutines. Run with -race.
+func TestGameStateConcurrency(t *testing.T) {
+	Config = make(map[string]string)
+	Config["turn_summaries"] = "true"
+	resetGame()
+	resetTurns()
+	resetReveals()
+	resetPickLedger()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			gameStartedEvent()
+			cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+			cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+			cardUpdatedEvent(testCardUpdatedMessage(10+i, "Burn", 2, 128, 0, 0))
+			playerUpdatedEvent(testPlayerUpdatedMessage(1, i))
+			addPendingPick("00000000-0000-0000-0000-000000000001", "draft")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			boardRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/board", nil))
+			turnsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/turns", nil))
+			revealsRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/reveals", nil))
+			reconcileRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/reconcile", nil))
+		}
+	}()
+	wg.Wait()
+	if !strings.Contains(sprintBoard(), "Burn") {
+		t.Errorf("board doesn't have the last Burn in it:\n%v", sprintBoard())
+	}
+	resetPickLedger()
+}
@@ -0,0 +1,162 @@
+// Local card database so we can turn UUIDs into names without having to ask a remote server
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cardDB maps card UUIDs to card names. It gets filled from the price feed, from names we see
+// in CardUpdated and DraftPack messages and from any import files listed in 'card_db_import'.
+// It's saved to the 'card_db_file' so names we've learned stick around between runs.
+var cardDB = make(map[string]string)
+
+// Lines in the card database (and import files) look like 'uuid : name'
+var cardDBLineRegexp = regexp.MustCompile(`^(\S+) : (.+)$`)
+
+// Read in the card database and any import files we've been told about
+func readCardDB() {
+	n := readCardDBFile(Config["card_db_file"])
+	Debug(Config["debug_card_db"], "[readCardDB] Read %v names from '%v'", n, Config["card_db_file"])
+	if Config["card_db_import"] == "" {
+		return
+	}
+	imported := 0
+	for _, fname := range strings.Split(Config["card_db_import"], ",") {
+		fname = strings.TrimSpace(fname)
+		if fname == "" {
+			continue
+		}
+		imported += readCardDBFile(fname)
+	}
+	if imported > 0 {
+		fmt.Printf("Imported %v card names from '%v'\n", imported, Config["card_db_import"])
+		writeCardDB()
+	}
+}
+
+// Read a file of 'uuid : name' lines into the card database. Returns how many names were added or changed.
+func readCardDBFile(fname string) int {
+	in, err := os.Open(fname)
+	if err != nil {
+		return 0
+	}
+	defer in.Close()
+	changed := 0
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		result := cardDBLineRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if len(result) == 0 {
+			continue
+		}
+		if recordCardName(result[1], result[2]) {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Write out the whole card database, sorted by UUID so diffs between runs are readable
+func writeCardDB() {
+	dbFile := Config["card_db_file"]
+	if dbFile == "" {
+		return
+	}
+	f, err := os.Create(dbFile)
+	if err != nil {
+		fmt.Printf("Could not create file %v for writing: %v\n", dbFile, err)
+		return
+	}
+	defer f.Close()
+	uuids := make([]string, 0, len(cardDB))
+	for uuid := range cardDB {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	w := bufio.NewWriter(f)
+	for _, uuid := range uuids {
+		fmt.Fprintf(w, "%v : %v\n", uuid, cardDB[uuid])
+	}
+	w.Flush()
+}
+
+// Put a name in the card database. Returns true if that was new information.
+func recordCardName(uuid string, name string) bool {
+	name = strings.TrimSpace(name)
+	if uuid == "" || name == "" || name == uuid || uuid == "00000000-0000-0000-0000-000000000000" {
+		return false
+	}
+	if cardDB[uuid] == name {
+		return false
+	}
+	cardDB[uuid] = name
+	// However we learned it, we don't need to ask anyone about this card any more
+	dropNameLookup(uuid)
+	// If the collection only knew this card by its UUID, give it its real name now
+	if c, ok := cardCollection[uuid]; ok && (c.name == "" || c.name == uuid) {
+		renameCollectionCard(uuid, name)
+	}
+	return true
+}
+
+// Record a name we learned outside of the price feed and append it to the card database file right away
+func learnCardName(uuid string, name string) {
+	if !recordCardName(uuid, name) {
+		return
+	}
+	name = cardDB[uuid]
+	Debug(Config["debug_card_db"], "[learnCardName] Learned '%v' is named '%v'", uuid, name)
+	dbFile := Config["card_db_file"]
+	if dbFile == "" {
+		return
+	}
+	f, err := os.OpenFile(dbFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		fmt.Printf("Could not append to file %v for writing: %v\n", dbFile, err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%v : %v\n", uuid, name)
+}
+
+// Pull a UUID and a name out of a card in an API message (if both are there) and remember them
+func learnCardNameFromJSON(card map[string]interface{}) {
+	guid, ok := card["Guid"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	uuid, _ := guid["m_Guid"].(string)
+	name, _ := card["Name"].(string)
+	learnCardName(uuid, name)
+}
+
+// Look up a name in the card database
+func lookupCardDB(uuid string) (string, bool) {
+	name, ok := cardDB[uuid]
+	return name, ok
+}
+
+// Give a card in our collection a new name. If we've got any of it, the CSV export has the
+// old name in it, so schedule a cache write the same way a collection update would.
+func renameCollectionCard(uuid string, name string) {
+	c := cardCollection[uuid]
+	if ntum[c.name] == uuid {
+		delete(ntum, c.name)
+	}
+	c.name = name
+	cardCollection[uuid] = c
+	ntum[name] = uuid
+	if c.qty == 0 && c.eaqty == 0 {
+		return
+	}
+	if collectionCacheTimer != nil {
+		collectionCacheTimer.Stop()
+	}
+	collectionCacheTimer = time.AfterFunc(collectionTimerPeriod, cacheCollection)
+}
@@ -0,0 +1,38 @@
+// Test cases for the local card database
+
+package main
+
+import "testing"
+
+func TestLearnCardName(t *testing.T) {
+	Config = make(map[string]string)
+	cardDB = make(map[string]string)
+	uuid := "ff9f4b37-6b97-4cc6-bbde-87974f1bb678"
+	cardCollection[uuid] = Card{name: uuid, uuid: uuid}
+	learnCardName(uuid, "Test Card")
+	if got, _ := lookupCardDB(uuid); got != "Test Card" {
+		t.Errorf("lookupCardDB(%v) == %q but we expected %q", uuid, got, "Test Card")
+	}
+	if got := getCardNameFromUUID(uuid); got != "Test Card" {
+		t.Errorf("getCardNameFromUUID(%v) == %q but we expected %q", uuid, got, "Test Card")
+	}
+	if recordCardName(uuid, uuid) {
+		t.Errorf("recordCardName(%v, %v) should not record a UUID as a name", uuid, uuid)
+	}
+}
+
+// A collection card named after its UUID is one we haven't named yet, so the card database still gets asked
+func TestCardNameForPlaceholder(t *testing.T) {
+	Config = make(map[string]string)
+	cardDB = make(map[string]string)
+	uuid := "ff9f4b37-6b97-4cc6-bbde-87974f1bb679"
+	cardCollection[uuid] = Card{name: uuid, uuid: uuid}
+	defer delete(cardCollection, uuid)
+	if got := getCardNameFromUUID(uuid); got != uuid {
+		t.Errorf("getCardNameFromUUID(%v) == %q before we know the name but we expected the UUID", uuid, got)
+	}
+	cardDB[uuid] = "Test Card"
+	if got := getCardNameFromUUID(uuid); got != "Test Card" {
+		t.Errorf("getCardNameFromUUID(%v) == %q but we expected %q", uuid, got, "Test Card")
+	}
+}
@@ -0,0 +1,319 @@
+// Card metadata beyond price: shard, cost, stats, type, set and rarity, along with searching on them
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The shards cards can belong to, in the order we list them
+var shardNames = []string{"Blood", "Diamond", "Ruby", "Sapphire", "Wild"}
+
+// CardUpdated messages can give Shards as a number. Nothing documents the bits, so we go with the
+// same order as the Thresholds in PlayerUpdated messages. Turn on 'debug_card_metadata' to see the
+// raw values if they look wrong.
+var cardShardFlags = flagTable{bits: shardNames}
+
+// cardMetadata is an entry in the 'card_metadata_file'. Anything left out is left alone.
+// Cards are matched by UUID if there is one and by name if there isn't.
+type cardMetadata struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Shard   string `json:"shard"`
+	Cost    *int   `json:"cost"`
+	Atk     *int   `json:"atk"`
+	Def     *int   `json:"def"`
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Set     string `json:"set"`
+	Rarity  string `json:"rarity"`
+}
+
+// Fill in metadata from a price feed entry. The feed always has the full rarity, and may have
+// the rest depending on which version of it we're talking to.
+func applyPriceFeedMetadata(uuid string, entry map[string]interface{}) {
+	c, ok := cardCollection[uuid]
+	if !ok {
+		return
+	}
+	if rarity, ok := entry["rarity"].(string); ok && rarity != "" {
+		c.fullRarity = rarity
+	}
+	// 'type' is usually "Card" or "Equipment", but when it's something like "Troop" that's the card type
+	if cardType, ok := entry["type"].(string); ok && cardType != "Card" && translateCardNature(cardType) == "Card" {
+		c.cardType = cardType
+	}
+	if cardType, ok := entry["card_type"].(string); ok && cardType != "" {
+		c.cardType = cardType
+	}
+	if shard, ok := entry["shard"].(string); ok && shard != "" {
+		c.shard = shard
+	}
+	if cost, ok := entry["cost"].(float64); ok {
+		c.cost = int(cost)
+		c.costKnown = true
+	}
+	if atk, ok := entry["attack"].(float64); ok {
+		c.atk = int(atk)
+	}
+	if def, ok := entry["defense"].(float64); ok {
+		c.def = int(def)
+	}
+	if subtype, ok := entry["subtype"].(string); ok && subtype != "" {
+		c.subtype = subtype
+	}
+	if set, ok := entry["set"].(string); ok && set != "" {
+		c.set = set
+	}
+	cardCollection[uuid] = c
+}
+
+// Read the local metadata file and apply it over what the price feed gave us
+func applyCardMetadataFile() {
+	blob, err := ioutil.ReadFile(Config["card_metadata_file"])
+	if err != nil {
+		return
+	}
+	var entries []cardMetadata
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		fmt.Printf("Could not read card metadata from %v: %v\n", Config["card_metadata_file"], err)
+		return
+	}
+	applied := 0
+	for _, m := range entries {
+		uuid := m.UUID
+		if uuid == "" {
+			uuid = ntum[m.Name]
+		}
+		c, ok := cardCollection[uuid]
+		if !ok {
+			continue
+		}
+		if m.Shard != "" {
+			c.shard = m.Shard
+		}
+		if m.Cost != nil {
+			c.cost = *m.Cost
+			c.costKnown = true
+		}
+		if m.Atk != nil {
+			c.atk = *m.Atk
+		}
+		if m.Def != nil {
+			c.def = *m.Def
+		}
+		if m.Type != "" {
+			c.cardType = m.Type
+		}
+		if m.Subtype != "" {
+			c.subtype = m.Subtype
+		}
+		if m.Set != "" {
+			c.set = m.Set
+		}
+		if m.Rarity != "" {
+			c.fullRarity = m.Rarity
+			c.rarity = m.Rarity[:1]
+		}
+		cardCollection[uuid] = c
+		applied++
+	}
+	Debug(Config["debug_card_metadata"], "[applyCardMetadataFile] Applied metadata for %v cards from %v", applied, Config["card_metadata_file"])
+}
+
+// CardUpdated messages tell us about cards as they're played. We match them up with our
+// collection by UUID if the message has one and by name if it doesn't. In-game stats can be
+// buffed or damaged, so we only use them to fill in things we don't know yet.
+func applyCardUpdatedMetadata(f map[string]interface{}) {
+	uuid := ""
+	if guid, ok := f["Guid"].(map[string]interface{}); ok {
+		uuid, _ = guid["m_Guid"].(string)
+	}
+	if uuid == "" {
+		name, _ := f["Name"].(string)
+		uuid = ntum[name]
+	}
+	c, ok := cardCollection[uuid]
+	if !ok {
+		return
+	}
+	if c.shard == "" {
+		c.shard = cardUpdatedShards(f["Shards"])
+	}
+	if cost, ok := f["Cost"].(float64); ok && !c.costKnown {
+		c.cost = int(cost)
+		c.costKnown = true
+	}
+	atk, atkOK := f["Attack"].(float64)
+	def, defOK := f["Defense"].(float64)
+	if atkOK && defOK && c.atk == 0 && c.def == 0 {
+		c.atk = int(atk)
+		c.def = int(def)
+	}
+	cardCollection[uuid] = c
+}
+
+// The shards from a CardUpdated message, which may be a name like "Ruby, Wild" or a number with a
+// bit for each shard. Blank if there aren't any.
+func cardUpdatedShards(shards interface{}) string {
+	Debug(Config["debug_card_metadata"], "[cardUpdatedShards] Shards: %#v", shards)
+	switch s := shards.(type) {
+	case string:
+		return s
+	case float64:
+		return strings.Join(cardShardFlags.decode(floatToInt(s)), ", ")
+	}
+	return ""
+}
+
+// Split a shard string like "Ruby, Wild" or "Ruby/Wild" into the shards in it. Cards without
+// a shard we know about come back as "Unknown".
+func splitShards(s string) []string {
+	var shards []string
+	for _, name := range shardNames {
+		if strings.Contains(strings.ToLower(s), strings.ToLower(name)) {
+			shards = append(shards, name)
+		}
+	}
+	if len(shards) == 0 {
+		return []string{"Unknown"}
+	}
+	return shards
+}
+
+// Turn a count map into "a 3, b 1" sorted by count (then name)
+func sprintCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%v %v", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Everything we know about a card on one line
+func sprintCardDetails(c Card) string {
+	kind := c.cardType
+	if c.subtype != "" {
+		kind = fmt.Sprintf("%v - %v", kind, c.subtype)
+	}
+	stats := ""
+	if c.atk != 0 || c.def != 0 {
+		stats = fmt.Sprintf(" %v/%v", c.atk, c.def)
+	}
+	return fmt.Sprintf("'%v' [%v] %v {%v} (%v)%v <%v> [Qty: %v (%v EA)] - %vp and %vg",
+		c.name, c.fullRarity, c.shard, kind, c.cost, stats, c.set, c.qty, c.eaqty, c.plat, c.gold)
+}
+
+// Does a card match every one of the filters? String filters match anywhere in the field and
+// ignore case. 'cost', 'atk' and 'def' have to match exactly. 'owned=true' means we have at least one.
+func cardMatches(c Card, filters map[string]string) bool {
+	for k, want := range filters {
+		have := ""
+		switch k {
+		case "name":
+			have = c.name
+		case "shard":
+			have = c.shard
+		case "type":
+			have = c.cardType
+		case "subtype":
+			have = c.subtype
+		case "set":
+			have = c.set
+		case "rarity":
+			have = c.fullRarity
+		case "nature":
+			have = c.nature
+		case "cost", "atk", "def":
+			n, err := strconv.Atoi(want)
+			if err != nil {
+				return false
+			}
+			if (k == "cost" && c.cost != n) || (k == "atk" && c.atk != n) || (k == "def" && c.def != n) {
+				return false
+			}
+			continue
+		case "owned":
+			if (want == "true") != (c.qty > 0) {
+				return false
+			}
+			continue
+		default:
+			return false
+		}
+		if !strings.Contains(strings.ToLower(have), strings.ToLower(want)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find every card matching the filters, sorted by name
+func searchCards(filters map[string]string) []Card {
+	var found []Card
+	for _, c := range cardCollection {
+		if cardMatches(c, filters) {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].name != found[j].name {
+			return found[i].name < found[j].name
+		}
+		return found[i].uuid < found[j].uuid
+	})
+	return found
+}
+
+// Turn 'key=value' arguments into search filters
+func parseSearchArgs(args []string) map[string]string {
+	filters := make(map[string]string)
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) == 2 {
+			filters[kv[0]] = kv[1]
+		} else {
+			filters["name"] = arg
+		}
+	}
+	return filters
+}
+
+// The filters in a request's query string. They're the same key=value pairs we take on the command line.
+func queryFilters(req *http.Request) map[string]string {
+	filters := make(map[string]string)
+	for k, v := range req.URL.Query() {
+		filters[k] = v[0]
+	}
+	return filters
+}
+
+func sprintSearchResults(found []Card) string {
+	s := ""
+	for _, c := range found {
+		s += sprintCardDetails(c) + "\n"
+	}
+	return s + fmt.Sprintf("%v cards found\n", len(found))
+}
+
+func searchRequest(rw http.ResponseWriter, req *http.Request) {
+	filters := queryFilters(req)
+	fmt.Printf("Request to search cards for %v received.\n", filters)
+	rw.Write([]byte(sprintSearchResults(searchCards(filters))))
+}
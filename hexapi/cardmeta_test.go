@@ -0,0 +1,86 @@
+// Test cases for card metadata
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyPriceFeedMetadata(t *testing.T) {
+	uuid := "ff9f4b37-6b97-4cc6-bbde-87974f1bb678"
+	cardCollection[uuid] = Card{name: "Test Card", uuid: uuid}
+	applyPriceFeedMetadata(uuid, map[string]interface{}{
+		"rarity": "Legendary", "type": "Troop", "shard": "Ruby", "cost": 3.0, "attack": 2.0, "defense": 4.0, "set": "Set 001",
+	})
+	c := cardCollection[uuid]
+	if c.fullRarity != "Legendary" || c.cardType != "Troop" || c.shard != "Ruby" || c.cost != 3 || c.atk != 2 || c.def != 4 || c.set != "Set 001" {
+		t.Errorf("applyPriceFeedMetadata() gave us %+v", c)
+	}
+}
+
+func TestApplyCardMetadataFile(t *testing.T) {
+	Config = make(map[string]string)
+	Config["card_metadata_file"] = filepath.Join(t.TempDir(), "meta.json")
+	uuid := "ff9f4b37-6b97-4cc6-bbde-87974f1bb678"
+	cardCollection[uuid] = Card{name: "Test Card", uuid: uuid, cost: 5, shard: "Wild"}
+	ntum["Test Card"] = uuid
+	ioutil.WriteFile(Config["card_metadata_file"], []byte(`[{"name": "Test Card", "cost": 0, "subtype": "Yeti", "rarity": "Rare"}]`), 0660)
+	applyCardMetadataFile()
+	c := cardCollection[uuid]
+	if c.cost != 0 || c.subtype != "Yeti" || c.rarity != "R" || c.fullRarity != "Rare" || c.shard != "Wild" {
+		t.Errorf("applyCardMetadataFile() gave us %+v", c)
+	}
+}
+
+func TestCardMatches(t *testing.T) {
+	c := Card{name: "Yeti Spy", shard: "Sapphire", cardType: "Troop", subtype: "Yeti", cost: 2, qty: 1, fullRarity: "Uncommon"}
+	for _, f := range []struct {
+		filters map[string]string
+		want    bool
+	}{
+		{map[string]string{"name": "yeti"}, true},
+		{map[string]string{"shard": "Sapphire", "type": "troop"}, true},
+		{map[string]string{"shard": "Ruby"}, false},
+		{map[string]string{"cost": "2", "owned": "true"}, true},
+		{map[string]string{"cost": "3"}, false},
+		{map[string]string{"owned": "false"}, false},
+		{map[string]string{"bogus": "x"}, false},
+	} {
+		got := cardMatches(c, f.filters)
+		if got != f.want {
+			t.Errorf("cardMatches(%v) == %v but we expected %v", f.filters, got, f.want)
+		}
+	}
+}
+
+func TestApplyCardUpdatedMetadata(t *testing.T) {
+	Config = make(map[string]string)
+	uuid := "ff9f4b37-6b97-4cc6-bbde-87974f1bb678"
+	for _, c := range []struct {
+		card      Card
+		shards    interface{}
+		cost      float64
+		wantShard string
+		wantCost  int
+	}{
+		{Card{}, "Ruby, Wild", 3, "Ruby, Wild", 3},
+		// Ruby is the third bit and Wild the fifth
+		{Card{}, float64(4 | 16), 3, "Ruby, Wild", 3},
+		{Card{}, float64(0), 0, "", 0},
+		// A cost of 0 we've been told about stays put, but one we haven't gets filled in
+		{Card{shard: "Sapphire", costKnown: true}, "Ruby", 2, "Sapphire", 0},
+		{Card{cost: 4}, nil, 2, "", 2},
+	} {
+		c.card.name, c.card.uuid = "Test Card", uuid
+		cardCollection[uuid] = c.card
+		applyCardUpdatedMetadata(map[string]interface{}{
+			"Name": "Test Card", "Guid": map[string]interface{}{"m_Guid": uuid}, "Shards": c.shards, "Cost": c.cost,
+		})
+		got := cardCollection[uuid]
+		if got.shard != c.wantShard || got.cost != c.wantCost || !got.costKnown {
+			t.Errorf("applyCardUpdatedMetadata() with shards %v and cost %v over %+v gave us %+v", c.shards, c.cost, c.card, got)
+		}
+	}
+}
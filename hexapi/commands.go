@@ -0,0 +1,150 @@
+// Commands we can run from the command line instead of listening for API events
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Run the command in 'args' and return the exit code we should use
+func runCommand(args []string) int {
+	switch args[0] {
+	case "drafts":
+		printDraftList()
+	case "draft":
+		id := ""
+		if len(args) > 1 {
+			id = args[1]
+		}
+		d := findDraft(id)
+		if d == nil {
+			fmt.Printf("Could not find a draft log for '%v' in '%v'\n", id, Config["draft_log_dir"])
+			return 1
+		}
+		reviewDraft(d, os.Stdin)
+	case "recap":
+		id := ""
+		if len(args) > 1 {
+			id = args[1]
+		}
+		d := findDraft(id)
+		if d == nil {
+			fmt.Printf("Could not find a draft log for '%v' in '%v'\n", id, Config["draft_log_dir"])
+			return 1
+		}
+		d.writeRecap()
+	case "simulate":
+		return runSimulation(args[1:])
+	case "wheels":
+		getCardPriceInfo()
+		learnWheelStats(readDraftLogs())
+		fmt.Print(wheelStatsReport())
+	case "history":
+		if len(args) < 2 {
+			fmt.Println("Usage: history <card name or uuid>")
+			return 1
+		}
+		fmt.Print(cardHistoryReport(strings.Join(args[1:], " ")))
+	case "setcounts":
+		if len(args) < 2 {
+			fmt.Println("Usage: setcounts <file>")
+			return 1
+		}
+		// Run this while we aren't listening for events, or the next cache save will undo it
+		getCardPriceInfo()
+		readAuditLog()
+		readCollectionCache()
+		return importCardCounts(args[1])
+	case "search":
+		getCardPriceInfo()
+		readAuditLog()
+		readCollectionCache()
+		fmt.Print(sprintSearchResults(searchCards(parseSearchArgs(args[1:]))))
+	case "games":
+		fmt.Print(matchHistoryReport(parseSearchArgs(args[1:])))
+	case "meta":
+		fmt.Print(metaReport(parseSearchArgs(args[1:])))
+	case "stats":
+		fmt.Print(matchStatsReport(parseSearchArgs(args[1:])))
+	case "decks":
+		getCardPriceInfo()
+		readDeckLibrary()
+		fmt.Print(deckListReport())
+	case "import":
+		if len(args) < 2 {
+			fmt.Println("Usage: import <deck list file>")
+			return 1
+		}
+		getCardPriceInfo()
+		readDeckLibrary()
+		return importDeckFile(args[1])
+	case "deck", "deckdiff", "complete", "export":
+		if len(args) < 2 {
+			fmt.Printf("Usage: %v <deck name> [revision]\n", args[0])
+			return 1
+		}
+		getCardPriceInfo()
+		readDeckLibrary()
+		var revs []int
+		for _, a := range args[2:] {
+			rev, err := strconv.Atoi(a)
+			if err != nil {
+				fmt.Printf("'%v' is not a revision number\n", a)
+				return 1
+			}
+			revs = append(revs, rev)
+		}
+		revs = append(revs, 0, 0)
+		switch args[0] {
+		case "deck":
+			fmt.Print(deckReport(args[1], revs[0]))
+		case "deckdiff":
+			fmt.Print(deckDiffReport(args[1], revs[0], revs[1]))
+		case "export":
+			r, err := findDeckRevision(args[1], revs[0])
+			if err != nil {
+				fmt.Printf("Could not export deck: %v\n", err)
+				return 1
+			}
+			fmt.Print(deckText(r))
+		case "complete":
+			readAuditLog()
+			readCollectionCache()
+			fmt.Print(deckCompletionReport(args[1], revs[0]))
+		}
+	case "ledger":
+		// We need current prices to value the pools as they stand today
+		getCardPriceInfo()
+		fmt.Print(ledgerReport())
+	default:
+		printUsage()
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	fmt.Printf("Usage: %v [command]\n", programName)
+	fmt.Println("With no command, we listen for API events from Hex. Commands are:")
+	fmt.Println("\tdrafts\t\tList the drafts we have logs for")
+	fmt.Println("\tdraft [id]\tStep through a draft one pick at a time (the most recent one if no id is given)")
+	fmt.Println("\trecap [id]\tPrint a draft recap and write it out as JSON and Markdown")
+	fmt.Println("\tledger\t\tShow lifetime, monthly and per format draft ROI")
+	fmt.Println("\tsimulate [-update] <messages> [golden]\tPlay recorded API messages back through the draft logic, optionally checking the output against a golden file")
+	fmt.Println("\twheels\t\tShow how often cards have come back around in our own drafts")
+	fmt.Println("\thistory <card>\tShow every recorded change to a card's count and where it came from")
+	fmt.Println("\tsetcounts <file>\tSet card counts from \"card : qty : eaqty\" lines, recorded in the history as a manual import")
+	fmt.Println("\tgames [key=value ...]\tList games played, filtered by id, champion, opponent, deck, format, result or days")
+	fmt.Println("\tstats [key=value ...]\tShow win rates and game lengths by champion, opponent, deck, month and format, with the same filters as games")
+	fmt.Println("\tmeta [key=value ...]\tShow the cards opponents have revealed, grouped by their champion, with the same filters as games")
+	fmt.Println("\tdecks\t\tList every deck we've saved, valued at current prices")
+	fmt.Println("\tdeck <name> [rev]\tShow a saved deck (the latest revision if no revision is given)")
+	fmt.Println("\tdeckdiff <name> [from] [to]\tShow what changed between two revisions of a deck (the last two if none are given)")
+	fmt.Println("\tcomplete <name> [rev]\tShow the cards we're missing for a saved deck and the cheapest way to buy them")
+	fmt.Println("\timport <file>\tAdd a \"4x Card Name\" deck list to the deck library")
+	fmt.Println("\texport <name> [rev]\tPrint a saved deck as a \"4x Card Name\" deck list")
+	fmt.Println("\tsearch [key=value ...]\tSearch cards by name, shard, type, subtype, set, rarity, nature, cost, atk, def or owned")
+}
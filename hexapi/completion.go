@@ -0,0 +1,157 @@
+// Deck completion: what we're missing to build a deck and what it'll cost to buy the rest
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// missingCard is a card a deck needs more copies of than we have
+type missingCard struct {
+	uuid    string
+	name    string
+	need    int
+	have    int
+	missing int
+	plat    int // 0 if the price feed doesn't have a plat price
+	gold    int // 0 if the price feed doesn't have a gold price
+	// Which currency is cheaper for this card ("plat", "gold", or "" if nobody has priced it)
+	buyWith string
+}
+
+// How many copies of each card a deck needs, counting the sideboard too
+func (r DeckRevision) cardsNeeded() map[string]int {
+	need := make(map[string]int)
+	for uuid, n := range r.Deck {
+		need[uuid] += n
+	}
+	for uuid, n := range r.Sideboard {
+		need[uuid] += n
+	}
+	return need
+}
+
+// The prices the price feed actually gave for a card. Whatever it didn't have is 0, rather than the
+// stand-in getCardPriceInfo fills in so other reports have something to work with.
+func (c Card) feedPrices() (int, int) {
+	plat, gold := c.plat, c.gold
+	if c.platGuessed {
+		plat = 0
+	}
+	if c.goldGuessed {
+		gold = 0
+	}
+	return plat, gold
+}
+
+// The cheaper currency to buy a card with. Gold is turned into plat at the rate draft packs go for.
+func cheaperCurrency(plat int, gold int) string {
+	switch {
+	case plat == 0 && gold == 0:
+		return ""
+	case gold == 0:
+		return "plat"
+	case plat == 0:
+		return "gold"
+	case goldPlatRatio > 0 && gold < plat*goldPlatRatio:
+		return "gold"
+	}
+	return "plat"
+}
+
+// Every card a deck needs that we don't have enough of, sorted by name. Extended art copies count
+// as copies we have.
+func deckMissingCards(r DeckRevision) []missingCard {
+	var missing []missingCard
+	for uuid, need := range r.cardsNeeded() {
+		c := cardCollection[uuid]
+		have := c.qty + c.eaqty
+		if have >= need {
+			continue
+		}
+		m := missingCard{uuid: uuid, name: getCardNameFromUUID(uuid), need: need, have: have, missing: need - have}
+		m.plat, m.gold = c.feedPrices()
+		m.buyWith = cheaperCurrency(m.plat, m.gold)
+		missing = append(missing, m)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].name < missing[j].name })
+	return missing
+}
+
+// What we're missing for a deck and what it costs to finish it in plat, in gold and buying each
+// card with whichever is cheaper
+func deckCompletionReport(name string, revision int) string {
+	r, err := findDeckRevision(name, revision)
+	if err != nil {
+		return fmt.Sprintf("Could not check deck: %v\n", err)
+	}
+	missing := deckMissingCards(r)
+	if len(missing) == 0 {
+		return fmt.Sprintf("We have every card for '%v' revision %v\n", r.Name, r.Revision)
+	}
+	s := fmt.Sprintf("==========================  CARDS MISSING FOR %v (rev %v)  ==========================\n", r.Name, r.Revision)
+	var cards, allPlat, allGold, cheapPlat, cheapGold, noPlat, noGold int
+	var unpriced []string
+	for _, m := range missing {
+		cards += m.missing
+		allPlat += m.plat * m.missing
+		allGold += m.gold * m.missing
+		if m.plat == 0 {
+			noPlat++
+		}
+		if m.gold == 0 {
+			noGold++
+		}
+		switch m.buyWith {
+		case "plat":
+			cheapPlat += m.plat * m.missing
+		case "gold":
+			cheapGold += m.gold * m.missing
+		default:
+			unpriced = append(unpriced, m.name)
+		}
+		buy := m.buyWith
+		if buy == "" {
+			buy = "no price"
+		}
+		s += fmt.Sprintf("\t%2dx %-40v (have %v of %v) %7v %9v each, buy with %v\n", m.missing, m.name, m.have, m.need, sprintFeedPrice(m.plat, "p"), sprintFeedPrice(m.gold, "g"), buy)
+	}
+	s += fmt.Sprintf("Missing %v cards. All in plat: %vp%v. All in gold: %vg%v.\n", cards, allPlat, sprintNoPrice(noPlat, "plat"), allGold, sprintNoPrice(noGold, "gold"))
+	s += fmt.Sprintf("Cheapest way to finish: %vp and %vg", cheapPlat, cheapGold)
+	if goldPlatRatio > 0 {
+		s += fmt.Sprintf(" (about %vp at %vg to the plat)", cheapPlat+cheapGold/goldPlatRatio, goldPlatRatio)
+	}
+	s += "\n"
+	if len(unpriced) > 0 {
+		s += fmt.Sprintf("No price for %v cards, so they aren't counted\n", len(unpriced))
+	}
+	return s
+}
+
+// A price from the feed, or "-" if it doesn't have one
+func sprintFeedPrice(price int, currency string) string {
+	if price == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%v%v", price, currency)
+}
+
+// A note on how many of the missing cards a total leaves out
+func sprintNoPrice(n int, currency string) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%v cards have no %v price)", n, currency)
+}
+
+func completionRequest(rw http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	rev, _ := strconv.Atoi(req.URL.Query().Get("rev"))
+	fmt.Printf("Request for cards missing from deck '%v' received.\n", name)
+	report := deckCompletionReport(name, rev)
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
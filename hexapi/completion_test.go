@@ -0,0 +1,119 @@
+// Test cases for deck completion
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheaperCurrency(t *testing.T) {
+	savedRatio := goldPlatRatio
+	goldPlatRatio = 100
+	defer func() { goldPlatRatio = savedRatio }()
+	for _, f := range []struct {
+		plat, gold int
+		want       string
+	}{
+		{0, 0, ""},
+		{5, 0, "plat"},
+		{0, 500, "gold"},
+		{5, 400, "gold"},
+		{5, 500, "plat"},
+		{5, 900, "plat"},
+	} {
+		if got := cheaperCurrency(f.plat, f.gold); got != f.want {
+			t.Errorf("cheaperCurrency(%v, %v) == %v but we expected %v", f.plat, f.gold, got, f.want)
+		}
+	}
+}
+
+func TestDeckCompletion(t *testing.T) {
+	uuids := testDraftSetup(t, 4)
+	savedRatio := goldPlatRatio
+	goldPlatRatio = 100
+	defer func() { goldPlatRatio = savedRatio }()
+	readDeckLibrary()
+	for i, have := range []int{3, 1, 0, 0} {
+		c := cardCollection[uuids[i]]
+		c.qty, c.eaqty = have, 0
+		cardCollection[uuids[i]] = c
+	}
+	// Card 1 has an extended art copy, and Card 2 is cheaper in gold
+	c := cardCollection[uuids[1]]
+	c.eaqty = 1
+	cardCollection[uuids[1]] = c
+	c = cardCollection[uuids[2]]
+	c.gold = 150
+	cardCollection[uuids[2]] = c
+
+	saveDeckRevision(DeckRevision{Name: "Yetis", Deck: map[string]int{uuids[0]: 2, uuids[1]: 3, uuids[2]: 4}, Sideboard: map[string]int{uuids[3]: 1}})
+	missing := deckMissingCards(deckLibrary["Yetis"][0])
+	if len(missing) != 3 {
+		t.Fatalf("deckMissingCards() found %v cards but we expected 3: %+v", len(missing), missing)
+	}
+	for i, f := range []struct {
+		name    string
+		missing int
+		buyWith string
+	}{
+		{"Card 1", 1, "plat"},
+		{"Card 2", 4, "gold"},
+		{"Card 3", 1, "plat"},
+	} {
+		if m := missing[i]; m.name != f.name || m.missing != f.missing || m.buyWith != f.buyWith {
+			t.Errorf("missing card %v is %v x%v with %v but we expected %v x%v with %v", i, m.name, m.missing, m.buyWith, f.name, f.missing, f.buyWith)
+		}
+	}
+	report := deckCompletionReport("Yetis", 0)
+	for _, want := range []string{
+		"Missing 6 cards. All in plat: 18p. All in gold: 1200g.",
+		"Cheapest way to finish: 6p and 600g (about 12p at 100g to the plat)",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("completion report does not contain %q:\n%v", want, report)
+		}
+	}
+}
+
+// Prices getCardPriceInfo made up because the feed didn't have them mustn't decide how we buy
+func TestDeckCompletionGuessedPrices(t *testing.T) {
+	uuids := testDraftSetup(t, 3)
+	savedRatio := goldPlatRatio
+	goldPlatRatio = 100
+	defer func() { goldPlatRatio = savedRatio }()
+	readDeckLibrary()
+	// Card 0 only has a gold price, and nobody has priced Card 1 or Card 2
+	for i, c := range []Card{
+		{plat: 1, platGuessed: true, gold: 150},
+		{plat: 1, platGuessed: true, gold: 1, goldGuessed: true},
+		{plat: 1, platGuessed: true, gold: 1, goldGuessed: true},
+	} {
+		c.name, c.uuid = cardCollection[uuids[i]].name, uuids[i]
+		cardCollection[uuids[i]] = c
+	}
+
+	saveDeckRevision(DeckRevision{Name: "Yetis", Deck: map[string]int{uuids[0]: 2, uuids[1]: 1, uuids[2]: 1}})
+	missing := deckMissingCards(deckLibrary["Yetis"][0])
+	if len(missing) != 3 {
+		t.Fatalf("deckMissingCards() found %v cards but we expected 3: %+v", len(missing), missing)
+	}
+	if m := missing[0]; m.plat != 0 || m.gold != 150 || m.buyWith != "gold" {
+		t.Errorf("gold only card is %vp %vg with %v but we expected 0p 150g with gold", m.plat, m.gold, m.buyWith)
+	}
+	for _, m := range missing[1:] {
+		if m.plat != 0 || m.gold != 0 || m.buyWith != "" {
+			t.Errorf("unpriced card %v is %vp %vg with %q but we expected no price", m.name, m.plat, m.gold, m.buyWith)
+		}
+	}
+	report := deckCompletionReport("Yetis", 0)
+	for _, want := range []string{
+		"All in plat: 0p (3 cards have no plat price). All in gold: 300g (2 cards have no gold price).",
+		"Cheapest way to finish: 0p and 300g",
+		"No price for 2 cards, so they aren't counted",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("completion report does not contain %q:\n%v", want, report)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Deck library: every deck we save, with a new revision each time it's saved again
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeckRevision is a deck as it was saved at one point. Cards are kept as UUID to count so we can
+// value them again at whatever prices are current. Plat and Gold are what it was worth when saved.
+type DeckRevision struct {
+	Name      string         `json:"name"`
+	Revision  int            `json:"revision"`
+	Saved     time.Time      `json:"saved"`
+	Champion  string         `json:"champion"`
+	Deck      map[string]int `json:"deck"`
+	Sideboard map[string]int `json:"sideboard,omitempty"`
+	Plat      int            `json:"plat"`
+	Gold      int            `json:"gold"`
+}
+
+// Every revision of every deck, oldest first, by deck name
+var deckLibrary = make(map[string][]DeckRevision)
+
+// What the latest revision of each deck was worth the last time we looked, so we can say when
+// a price refresh changes it
+var deckValues = make(map[string][2]int)
+
+// deckLibraryMutex guards deckLibrary and deckValues. SaveDeck events and price refreshes change
+// them while the /decks, /import and /export handlers are reading them on the HTTP server's goroutines.
+var deckLibraryMutex sync.Mutex
+
+// Count up the cards in a SaveDeck card array. Every copy of a card is its own entry.
+func deckCardCounts(cards []interface{}) map[string]int {
+	counts := make(map[string]int)
+	for _, u := range cards {
+		card, ok := u.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		counts[getCardUUIDFromJSON(card)]++
+	}
+	return counts
+}
+
+// What a set of cards is worth at current prices
+func cardCountsValue(counts map[string]int) (plat, gold int) {
+	for uuid, n := range counts {
+		c := cardCollection[uuid]
+		plat += c.plat * n
+		gold += c.gold * n
+	}
+	return
+}
+
+// What a deck (and its sideboard) is worth at current prices
+func (r DeckRevision) currentValue() (plat, gold int) {
+	plat, gold = cardCountsValue(r.Deck)
+	p, g := cardCountsValue(r.Sideboard)
+	return plat + p, gold + g
+}
+
+func (r DeckRevision) cardCount() int {
+	n := 0
+	for _, count := range r.Deck {
+		n += count
+	}
+	return n
+}
+
+// Add a deck to the library as its next revision and append it to the library file
+func saveDeckRevision(r DeckRevision) DeckRevision {
+	deckLibraryMutex.Lock()
+	defer deckLibraryMutex.Unlock()
+	r.Revision = len(deckLibrary[r.Name]) + 1
+	r.Saved = now()
+	r.Plat, r.Gold = r.currentValue()
+	deckLibrary[r.Name] = append(deckLibrary[r.Name], r)
+	deckValues[r.Name] = [2]int{r.Plat, r.Gold}
+	libraryFile := Config["deck_library_file"]
+	if libraryFile == "" {
+		return r
+	}
+	appendJSONLines(libraryFile, r)
+	return r
+}
+
+// Load the deck library. Prices need to be loaded first so we know what each deck is worth now.
+func readDeckLibrary() {
+	deckLibraryMutex.Lock()
+	defer deckLibraryMutex.Unlock()
+	deckLibrary = make(map[string][]DeckRevision)
+	deckValues = make(map[string][2]int)
+	readJSONLines(Config["deck_library_file"], func(line []byte) {
+		var r DeckRevision
+		if err := json.Unmarshal(line, &r); err == nil {
+			deckLibrary[r.Name] = append(deckLibrary[r.Name], r)
+		}
+	})
+	for name, revisions := range deckLibrary {
+		plat, gold := revisions[len(revisions)-1].currentValue()
+		deckValues[name] = [2]int{plat, gold}
+	}
+}
+
+// Value every deck again after a price refresh and say which ones changed
+func revalueDecks() {
+	deckLibraryMutex.Lock()
+	defer deckLibraryMutex.Unlock()
+	for _, name := range deckNames() {
+		revisions := deckLibrary[name]
+		plat, gold := revisions[len(revisions)-1].currentValue()
+		was := deckValues[name]
+		if was[0] != plat || was[1] != gold {
+			fmt.Printf("Deck '%v' is now worth %vp and %vg (was %vp and %vg)\n", name, plat, gold, was[0], was[1])
+		}
+		deckValues[name] = [2]int{plat, gold}
+	}
+}
+
+// The caller holds deckLibraryMutex
+func deckNames() []string {
+	var names []string
+	for name := range deckLibrary {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find a revision of a deck by name (ignoring case if we have to). Revision 0 is the latest.
+func findDeckRevision(name string, revision int) (DeckRevision, error) {
+	deckLibraryMutex.Lock()
+	defer deckLibraryMutex.Unlock()
+	revisions, ok := deckLibrary[name]
+	if !ok {
+		for n, r := range deckLibrary {
+			if strings.EqualFold(n, name) {
+				revisions, ok = r, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return DeckRevision{}, fmt.Errorf("no deck called '%v' in the library", name)
+	}
+	if revision == 0 {
+		return revisions[len(revisions)-1], nil
+	}
+	if revision < 1 || revision > len(revisions) {
+		return DeckRevision{}, fmt.Errorf("deck '%v' only has revisions 1 to %v", name, len(revisions))
+	}
+	return revisions[revision-1], nil
+}
+
+// Every deck in the library, latest revision, valued at current prices
+func deckListReport() string {
+	deckLibraryMutex.Lock()
+	defer deckLibraryMutex.Unlock()
+	names := deckNames()
+	if len(names) == 0 {
+		return fmt.Sprintf("No decks saved in '%v' yet\n", Config["deck_library_file"])
+	}
+	s := "==========================           DECK LIBRARY           ==========================\n"
+	for _, name := range names {
+		r := deckLibrary[name][len(deckLibrary[name])-1]
+		plat, gold := r.currentValue()
+		s += fmt.Sprintf("%-30v rev %-3v %-30v %3v cards %6vp %8vg (saved %v)\n", name, r.Revision, r.Champion, r.cardCount(), plat, gold, r.Saved.Format("2006-01-02 15:04"))
+	}
+	return s
+}
+
+// cardLine is a card and how many of it
+type cardLine struct {
+	name  string
+	count int
+}
+
+// Turn UUID counts into named lines sorted by name
+func sortedCardLines(counts map[string]int) []cardLine {
+	var lines []cardLine
+	for uuid, n := range counts {
+		lines = append(lines, cardLine{getCardNameFromUUID(uuid), n})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].name < lines[j].name })
+	return lines
+}
+
+// Cards sorted by name, one per line
+func sprintCardCounts(counts map[string]int) string {
+	s := ""
+	for _, l := range sortedCardLines(counts) {
+		s += fmt.Sprintf("\t%2dx %v\n", l.count, l.name)
+	}
+	return s
+}
+
+// One revision of a deck in full
+func deckReport(name string, revision int) string {
+	r, err := findDeckRevision(name, revision)
+	if err != nil {
+		return fmt.Sprintf("Could not show deck: %v\n", err)
+	}
+	latest, _ := findDeckRevision(r.Name, 0)
+	plat, gold := r.currentValue()
+	s := fmt.Sprintf("Deck '%v' revision %v of %v, saved %v\n", r.Name, r.Revision, latest.Revision, r.Saved.Format("2006-01-02 15:04"))
+	s += fmt.Sprintf("Champion: %v\n", r.Champion)
+	s += fmt.Sprintf("Worth %vp and %vg now (%vp and %vg when saved)\n", plat, gold, r.Plat, r.Gold)
+	s += fmt.Sprintf("Deck (%v cards):\n", r.cardCount())
+	s += sprintCardCounts(r.Deck)
+	if len(r.Sideboard) > 0 {
+		s += "Sideboard:\n"
+		s += sprintCardCounts(r.Sideboard)
+	}
+	return s
+}
+
+// What changed in a set of cards, as "+2 Name" and "-1 Name" lines sorted by name
+func cardCountChanges(from map[string]int, to map[string]int) string {
+	changes := make(map[string]int)
+	for uuid, n := range to {
+		changes[uuid] += n
+	}
+	for uuid, n := range from {
+		changes[uuid] -= n
+	}
+	s := ""
+	for _, l := range sortedCardLines(changes) {
+		if l.count != 0 {
+			s += fmt.Sprintf("\t%+d %v\n", l.count, l.name)
+		}
+	}
+	return s
+}
+
+// The differences between two revisions of a deck. Revision 0 means the latest, and if 'from' is
+// 0 as well we compare against the one before it.
+func deckDiffReport(name string, from int, to int) string {
+	b, err := findDeckRevision(name, to)
+	if err != nil {
+		return fmt.Sprintf("Could not diff deck: %v\n", err)
+	}
+	if from == 0 {
+		from = b.Revision - 1
+	}
+	if from == 0 {
+		return fmt.Sprintf("Deck '%v' only has the one revision\n", b.Name)
+	}
+	a, err := findDeckRevision(b.Name, from)
+	if err != nil {
+		return fmt.Sprintf("Could not diff deck: %v\n", err)
+	}
+	s := fmt.Sprintf("Deck '%v' revision %v -> %v\n", b.Name, a.Revision, b.Revision)
+	if a.Champion != b.Champion {
+		s += fmt.Sprintf("Champion: %v -> %v\n", a.Champion, b.Champion)
+	}
+	if changes := cardCountChanges(a.Deck, b.Deck); changes != "" {
+		s += "Deck:\n" + changes
+	}
+	if changes := cardCountChanges(a.Sideboard, b.Sideboard); changes != "" {
+		s += "Sideboard:\n" + changes
+	}
+	ap, ag := a.currentValue()
+	bp, bg := b.currentValue()
+	s += fmt.Sprintf("Value at current prices: %vp and %vg -> %vp and %vg\n", ap, ag, bp, bg)
+	return s
+}
+
+// With no name we list the library. With a name we show the deck, or the differences between two
+// revisions if 'from' is given.
+func decksRequest(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	name := q.Get("name")
+	rev, _ := strconv.Atoi(q.Get("rev"))
+	fmt.Printf("Request for deck library '%v' received.\n", name)
+	var report string
+	switch {
+	case name == "":
+		report = deckListReport()
+	case q.Get("from") != "":
+		from, _ := strconv.Atoi(q.Get("from"))
+		report = deckDiffReport(name, from, rev)
+	default:
+		report = deckReport(name, rev)
+	}
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
@@ -0,0 +1,92 @@
+// Test cases for the deck library
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testSaveDeckMessage(name string, champion string, deck []string, sideboard []string) map[string]interface{} {
+	cards := func(uuids []string) []interface{} {
+		var list []interface{}
+		for _, uuid := range uuids {
+			list = append(list, map[string]interface{}{"Guid": map[string]interface{}{"m_Guid": uuid}, "Flags": ""})
+		}
+		return list
+	}
+	return map[string]interface{}{"Message": "SaveDeck", "Name": name, "Champion": champion, "Deck": cards(deck), "Sideboard": cards(sideboard)}
+}
+
+func TestDeckLibrary(t *testing.T) {
+	uuids := testDraftSetup(t, 4)
+	Config["deck_library_file"] = filepath.Join(t.TempDir(), "deck_library.jsonl")
+	readDeckLibrary()
+
+	saveDeckEvent(testSaveDeckMessage("Yetis", "Uzume", []string{uuids[0], uuids[0], uuids[1]}, []string{uuids[2]}))
+	saveDeckEvent(testSaveDeckMessage("Yetis", "Uzume", []string{uuids[0], uuids[1], uuids[1], uuids[3]}, nil))
+	saveDeckEvent(testSaveDeckMessage("Other", "Zared", []string{uuids[2]}, nil))
+
+	// Reading the file back should give us the same library
+	readDeckLibrary()
+	if n := len(deckLibrary["Yetis"]); n != 2 {
+		t.Fatalf("deck library has %v revisions of Yetis but we expected 2", n)
+	}
+	first := deckLibrary["Yetis"][0]
+	if first.Revision != 1 || first.Deck[uuids[0]] != 2 || first.Sideboard[uuids[2]] != 1 || first.Plat != 7 {
+		t.Errorf("first revision of Yetis is %+v but we expected 2x Card 0, 1x Card 1, Card 2 in the sideboard and 7p", first)
+	}
+
+	for _, f := range []struct {
+		report string
+		want   []string
+	}{
+		{deckListReport(), []string{"Other", "Yetis                          rev 2"}},
+		{deckReport("yetis", 1), []string{"revision 1 of 2", " 2x Card 0", "Sideboard:\n\t 1x Card 2"}},
+		{deckDiffReport("Yetis", 0, 0), []string{"revision 1 -> 2", "Deck:\n\t-1 Card 0\n\t+1 Card 1\n\t+1 Card 3\n", "Sideboard:\n\t-1 Card 2\n", "7p and 700g -> 9p and 900g"}},
+		{deckDiffReport("Other", 0, 0), []string{"only has the one revision"}},
+		{deckReport("Yetis", 3), []string{"only has revisions 1 to 2"}},
+	} {
+		for _, want := range f.want {
+			if !strings.Contains(f.report, want) {
+				t.Errorf("report does not contain %q:\n%v", want, f.report)
+			}
+		}
+	}
+
+	// Prices go up, so the deck is worth more
+	c := cardCollection[uuids[3]]
+	c.plat = 14
+	cardCollection[uuids[3]] = c
+	revalueDecks()
+	if v := deckValues["Yetis"]; v[0] != 19 {
+		t.Errorf("Yetis is worth %vp after revaluing but we expected 19p", v[0])
+	}
+}
+
+// Run with -race: decks get saved by the event handlers while the HTTP handlers read the library
+func TestDeckLibraryConcurrency(t *testing.T) {
+	uuids := testDraftSetup(t, 2)
+	Config["deck_library_file"] = filepath.Join(t.TempDir(), "deck_library.jsonl")
+	readDeckLibrary()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			saveDeckRevision(DeckRevision{Name: "Yetis", Champion: "Uzume", Deck: map[string]int{uuids[0]: 4}})
+		}()
+		go func() {
+			defer wg.Done()
+			deckListReport()
+			deckReport("Yetis", 0)
+		}()
+	}
+	wg.Wait()
+	if r, err := findDeckRevision("Yetis", 0); err != nil || r.Revision != 4 {
+		t.Errorf("latest revision of Yetis is %v (%v) but we expected 4", r.Revision, err)
+	}
+}
@@ -0,0 +1,245 @@
+// Plain text deck lists ("4x Card Name"), so decks can be swapped in chat and brought into the library
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A card line is "4x Card Name", "4 Card Name" or just "Card Name" for a single copy
+var deckTextLineRegexp = regexp.MustCompile(`^(?:(\d+)\s*[xX]?\s+)?(.+?)$`)
+
+// deckText writes the UUID for cards we don't have a name for yet, so a line can be one of those too
+var deckTextUUIDRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Every card name we know about (lower case) and its UUID. The price feed's name to UUID map wins
+// when a name has more than one UUID.
+func cardNameIndex() map[string]string {
+	index := make(map[string]string)
+	for uuid, name := range cardDB {
+		index[strings.ToLower(name)] = uuid
+	}
+	for uuid, c := range cardCollection {
+		if c.nature != "Inventory" && c.name != "" && c.name != uuid {
+			index[strings.ToLower(c.name)] = uuid
+		}
+	}
+	for name, uuid := range ntum {
+		index[strings.ToLower(name)] = uuid
+	}
+	return index
+}
+
+// How many single character edits it takes to turn a into b
+func editDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Up to 'n' card names close to one we couldn't find, closest first. Names with the text in them
+// count as close too, which catches "Yeti" for "Yeti Spy".
+func suggestCardNames(index map[string]string, name string, n int) []string {
+	name = strings.ToLower(name)
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var found []suggestion
+	limit := len(name)/4 + 1
+	for known, uuid := range index {
+		d := editDistance(name, known)
+		if d > limit && strings.Contains(known, name) {
+			d = limit
+		}
+		if d <= limit {
+			found = append(found, suggestion{getCardNameFromUUID(uuid), d})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].name < found[j].name
+	})
+	var names []string
+	for i := 0; i < len(found) && i < n; i++ {
+		names = append(names, found[i].name)
+	}
+	return names
+}
+
+// Read a deck list. Lines can be "Name: ...", "Champion: ...", "Deck" or "Sideboard" (with or
+// without a colon) to start a section, or cards. Blank lines and lines starting with # are skipped.
+// Anything we can't make sense of comes back as a problem, with suggestions for names we don't know.
+func parseDeckText(in io.Reader) (DeckRevision, []string) {
+	r := DeckRevision{Deck: make(map[string]int), Sideboard: make(map[string]int)}
+	var problems []string
+	index := cardNameIndex()
+	section := r.Deck
+	scanner := bufio.NewScanner(in)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "name:"):
+			r.Name = strings.TrimSpace(line[len("name:"):])
+			continue
+		case strings.HasPrefix(lower, "champion:"):
+			r.Champion = strings.TrimSpace(line[len("champion:"):])
+			continue
+		case lower == "deck" || lower == "deck:" || lower == "main" || lower == "main:":
+			section = r.Deck
+			continue
+		case lower == "sideboard" || lower == "sideboard:":
+			section = r.Sideboard
+			continue
+		}
+		m := deckTextLineRegexp.FindStringSubmatch(line)
+		count := 1
+		if m[1] != "" {
+			count, _ = strconv.Atoi(m[1])
+		}
+		uuid, ok := index[strings.ToLower(m[2])]
+		if !ok && deckTextUUIDRegexp.MatchString(m[2]) {
+			uuid, ok = m[2], true
+		}
+		if !ok {
+			problem := fmt.Sprintf("line %v: no card called '%v'", lineNum, m[2])
+			if suggestions := suggestCardNames(index, m[2], 3); len(suggestions) > 0 {
+				problem += fmt.Sprintf(" (did you mean %v?)", strings.Join(suggestions, ", "))
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		section[uuid] += count
+	}
+	return r, problems
+}
+
+// Write a deck out in the same format parseDeckText reads
+func deckText(r DeckRevision) string {
+	s := fmt.Sprintf("Name: %v\n", r.Name)
+	s += fmt.Sprintf("Champion: %v\n", r.Champion)
+	s += "Deck:\n"
+	for _, l := range sortedCardLines(r.Deck) {
+		s += fmt.Sprintf("%vx %v\n", l.count, l.name)
+	}
+	if len(r.Sideboard) > 0 {
+		s += "Sideboard:\n"
+		for _, l := range sortedCardLines(r.Sideboard) {
+			s += fmt.Sprintf("%vx %v\n", l.count, l.name)
+		}
+	}
+	return s
+}
+
+// Read a deck list and put it in the library as the next revision of the deck. If the list doesn't
+// have a name in it we use 'name'. Nothing is saved if there were any problems.
+func importDeckText(in io.Reader, name string) (DeckRevision, []string) {
+	r, problems := parseDeckText(in)
+	if r.Name == "" {
+		r.Name = name
+	}
+	if r.Name == "" {
+		problems = append(problems, "the deck needs a name")
+	}
+	if len(r.Deck) == 0 && len(r.Sideboard) == 0 && len(problems) == 0 {
+		problems = append(problems, "there are no cards in the deck")
+	}
+	if len(problems) > 0 {
+		return r, problems
+	}
+	return saveDeckRevision(r), nil
+}
+
+func sprintDeckImport(r DeckRevision, problems []string) string {
+	if len(problems) > 0 {
+		s := fmt.Sprintf("Could not import deck '%v':\n", r.Name)
+		for _, p := range problems {
+			s += fmt.Sprintf("\t%v\n", p)
+		}
+		return s
+	}
+	return fmt.Sprintf("Imported deck '%v' as revision %v (%v cards)\n", r.Name, r.Revision, r.cardCount())
+}
+
+// Import a deck list from a file. The file name (without the extension) is the deck name if the list doesn't have one.
+func importDeckFile(file string) int {
+	in, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("Could not open deck list '%v': %v\n", file, err)
+		return 1
+	}
+	defer in.Close()
+	r, problems := importDeckText(in, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+	fmt.Print(sprintDeckImport(r, problems))
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// POST a deck list to /import?name=... to add it to the library
+func importRequest(rw http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	fmt.Printf("Request to import deck '%v' received.\n", name)
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.Write([]byte(fmt.Sprintf("Could not read deck list: %v\n", err)))
+		return
+	}
+	report := sprintDeckImport(importDeckText(strings.NewReader(string(body)), name))
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
+
+func exportRequest(rw http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	rev, _ := strconv.Atoi(req.URL.Query().Get("rev"))
+	fmt.Printf("Request to export deck '%v' received.\n", name)
+	r, err := findDeckRevision(name, rev)
+	if err != nil {
+		rw.Write([]byte(fmt.Sprintf("Could not export deck: %v\n", err)))
+		return
+	}
+	rw.Write([]byte(deckText(r)))
+}
@@ -0,0 +1,95 @@
+// Test cases for plain text deck lists
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, f := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"yeti spy", "yeti spy", 0},
+		{"yeti spyy", "yeti spy", 1},
+		{"yeit spy", "yeti spy", 2},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(f.a, f.b); got != f.want {
+			t.Errorf("editDistance(%q, %q) == %v but we expected %v", f.a, f.b, got, f.want)
+		}
+	}
+}
+
+func TestDeckText(t *testing.T) {
+	uuids := testDraftSetup(t, 12)
+	readDeckLibrary()
+	list := `# Passed around in team chat
+Name: Yetis
+Champion: Uzume
+4x Card 1
+2 card 2
+Card 3
+
+Sideboard:
+1x Card 10
+`
+	r, problems := importDeckText(strings.NewReader(list), "ignored")
+	if len(problems) > 0 {
+		t.Fatalf("importDeckText() had problems: %v", problems)
+	}
+	want := map[string]int{uuids[1]: 4, uuids[2]: 2, uuids[3]: 1}
+	if r.Name != "Yetis" || r.Champion != "Uzume" || !reflect.DeepEqual(r.Deck, want) || r.Sideboard[uuids[10]] != 1 {
+		t.Errorf("importDeckText() == %+v but we expected Yetis for Uzume with %v and Card 10 in the sideboard", r, want)
+	}
+	if len(deckLibrary["Yetis"]) != 1 {
+		t.Errorf("the library has %v revisions of Yetis after the import but we expected 1", len(deckLibrary["Yetis"]))
+	}
+
+	// Export and import again and we should get the same deck
+	text := deckText(r)
+	if !strings.Contains(text, "Deck:\n4x Card 1\n2x Card 2\n1x Card 3\nSideboard:\n1x Card 10\n") {
+		t.Errorf("deckText() == \n%v\nwhich isn't the deck we imported", text)
+	}
+	again, problems := parseDeckText(strings.NewReader(text))
+	if len(problems) > 0 || !reflect.DeepEqual(again.Deck, r.Deck) || !reflect.DeepEqual(again.Sideboard, r.Sideboard) {
+		t.Errorf("parseDeckText(deckText()) == %+v (problems %v) but we expected %+v", again, problems, r)
+	}
+
+	// Typos get suggestions and nothing gets saved
+	_, problems = importDeckText(strings.NewReader("Name: Typos\n3x Crad 1\n1x Nothing Like It\n"), "")
+	if len(problems) != 2 || !strings.Contains(problems[0], "line 2: no card called 'Crad 1' (did you mean Card 1") || strings.Contains(problems[1], "did you mean") {
+		t.Errorf("importDeckText() with typos gave us problems %v", problems)
+	}
+	if _, ok := deckLibrary["Typos"]; ok {
+		t.Errorf("a deck with typos in it made it into the library")
+	}
+}
+
+// Names only the price feed knows and cards we don't have a name for yet both have to survive an export and import
+func TestDeckTextUnnamedCards(t *testing.T) {
+	uuids := testDraftSetup(t, 2)
+	readDeckLibrary()
+	ntum["Feed Only Card"] = uuids[1]
+	defer delete(ntum, "Feed Only Card")
+	unnamed := "ab12cd34-0000-4000-8000-00000000abcd"
+
+	r, problems := parseDeckText(strings.NewReader("Name: Mystery\n2x Feed Only Card\n3x " + unnamed + "\n"))
+	want := map[string]int{uuids[1]: 2, unnamed: 3}
+	if len(problems) > 0 || !reflect.DeepEqual(r.Deck, want) {
+		t.Fatalf("parseDeckText() == %v (problems %v) but we expected %v", r.Deck, problems, want)
+	}
+	text := deckText(r)
+	if !strings.Contains(text, "3x "+unnamed+"\n") {
+		t.Errorf("deckText() == \n%v\nbut we expected the unnamed card as its UUID", text)
+	}
+	again, problems := parseDeckText(strings.NewReader(text))
+	if len(problems) > 0 || !reflect.DeepEqual(again.Deck, r.Deck) {
+		t.Errorf("parseDeckText(deckText()) == %v (problems %v) but we expected %v", again.Deck, problems, r.Deck)
+	}
+}
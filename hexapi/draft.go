@@ -0,0 +1,364 @@
+// Draft sessions: every pack we see and every pick we make, saved to a log file per draft
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DraftCard is a card as it looked when we saw it in a pack
+type DraftCard struct {
+	UUID   string `json:"uuid"`
+	Name   string `json:"name"`
+	Rarity string `json:"rarity"`
+	Qty    int    `json:"qty"`
+	Plat   int    `json:"plat"`
+	Gold   int    `json:"gold"`
+	Wheel  int    `json:"wheel"`
+	Shard  string `json:"shard,omitempty"`
+	Cost   int    `json:"cost,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// DraftPack is a pack we were handed. Size is how many cards were in it, which is also how we
+// refer to the pick (17 is the first pick of a round, 1 is the last). Once a pack comes back
+// around, Wheeled is what was still there from its first lap and Missing is what other folks took.
+type DraftPack struct {
+	Round   int         `json:"round"`
+	Size    int         `json:"size"`
+	Seen    time.Time   `json:"seen"`
+	Cards   []DraftCard `json:"cards"`
+	Wheeled []DraftCard `json:"wheeled,omitempty"`
+	Missing []DraftCard `json:"missing,omitempty"`
+}
+
+// DraftPick is a card we took
+type DraftPick struct {
+	Round  int       `json:"round"`
+	Size   int       `json:"size"`
+	Picked time.Time `json:"picked"`
+	Card   DraftCard `json:"card"`
+}
+
+// Draft is everything we know about a draft, from the first pack we're shown to the last pick
+type Draft struct {
+	ID           string      `json:"id"`
+	Format       string      `json:"format"`
+	Started      time.Time   `json:"started"`
+	Ended        time.Time   `json:"ended"`
+	Finished     bool        `json:"finished"`
+	Rounds       int         `json:"rounds"`
+	Round        int         `json:"round"`
+	PackCost     int         `json:"pack_cost"`
+	PackGoldCost int         `json:"pack_gold_cost"`
+	Packs        []DraftPack `json:"packs"`
+	Picks        []DraftPick `json:"picks"`
+
+	// Where we are in the current booster round
+	PackNum          int        `json:"pack_num"`
+	PackValue        int        `json:"pack_value"`
+	PackGoldValue    int        `json:"pack_gold_value"`
+	PackContents     [18]string `json:"pack_contents"`
+	PreviousContents [18]string `json:"previous_contents"`
+	LastPack         string     `json:"last_pack"`
+}
+
+// The draft we're in the middle of (or the last one we finished)
+var currentDraft *Draft
+
+// Start up a new draft and make it the current one
+func newDraft(format string) *Draft {
+	rounds, err := strconv.Atoi(Config["draft_rounds"])
+	if err != nil || rounds < 1 {
+		rounds = 3
+	}
+	if format == "" {
+		format = "Draft"
+	}
+	started := now()
+	d := &Draft{
+		ID:           started.Format("20060102-150405"),
+		Format:       format,
+		Started:      started,
+		Rounds:       rounds,
+		Round:        1,
+		PackCost:     packCost,
+		PackGoldCost: packGoldCost,
+	}
+	currentDraft = d
+	return d
+}
+
+// Figure out which draft a pack of 'size' cards belongs to. A full pack either starts a new
+// draft or moves us on to the next round. If we get a full pack while we're part way through
+// a round, whatever we were doing before got abandoned and this is a new draft.
+func draftForPack(size int, format string) *Draft {
+	d := currentDraft
+	if d == nil || d.Finished {
+		return newDraft(format)
+	}
+	if size == packSize {
+		if d.roundComplete() {
+			d.Round++
+		} else if len(d.picksInRound(d.Round)) > 0 {
+			fmt.Printf("Got a new pack part way through round %v of the draft started at %v. Starting a new draft.\n", d.Round, d.Started.Format(time.UnixDate))
+			d.finish()
+			return newDraft(format)
+		}
+	}
+	return d
+}
+
+// Same idea for picks, but we never move rounds on a pick
+func draftForPick() *Draft {
+	if currentDraft == nil || currentDraft.Finished {
+		return newDraft("")
+	}
+	return currentDraft
+}
+
+// Have we made the last pick of the current round?
+func (d *Draft) roundComplete() bool {
+	picks := d.picksInRound(d.Round)
+	return len(picks) > 0 && picks[len(picks)-1].Size == 1
+}
+
+func (d *Draft) picksInRound(round int) []DraftPick {
+	var picks []DraftPick
+	for _, p := range d.Picks {
+		if p.Round == round {
+			picks = append(picks, p)
+		}
+	}
+	return picks
+}
+
+// Find the pack of 'size' cards we were shown in 'round'
+func (d *Draft) packFor(round int, size int) *DraftPack {
+	for i := len(d.Packs) - 1; i >= 0; i-- {
+		if d.Packs[i].Round == round && d.Packs[i].Size == size {
+			return &d.Packs[i]
+		}
+	}
+	return nil
+}
+
+// Find the pick we made from the pack of 'size' cards in 'round'
+func (d *Draft) pickFor(round int, size int) *DraftPick {
+	for i := len(d.Picks) - 1; i >= 0; i-- {
+		if d.Picks[i].Round == round && d.Picks[i].Size == size {
+			return &d.Picks[i]
+		}
+	}
+	return nil
+}
+
+// Snapshot a card from our collection the way it looks right now
+func draftCardFromCollection(uuid string, wheelPackNum int) DraftCard {
+	c := cardCollection[uuid]
+	name := c.name
+	if name == "" {
+		name = getCardNameFromUUID(uuid)
+	}
+	return DraftCard{UUID: uuid, Name: name, Rarity: c.rarity, Qty: c.qty, Plat: c.plat, Gold: c.gold, Wheel: wheelChance(c, wheelPackNum),
+		Shard: c.shard, Cost: c.cost, Type: c.cardType}
+}
+
+// Record a pack. If this is a pack coming back around, work out what wheeled and what went missing.
+func (d *Draft) addPack(pack DraftPack) {
+	if first := d.packFor(pack.Round, pack.Size+8); first != nil {
+		left := make(map[string]int)
+		for _, c := range pack.Cards {
+			left[c.UUID]++
+		}
+		// We don't want our own pick from the first lap showing up as missing
+		ours := ""
+		if p := d.pickFor(pack.Round, first.Size); p != nil {
+			ours = p.Card.UUID
+		}
+		for _, c := range first.Cards {
+			switch {
+			case left[c.UUID] > 0:
+				left[c.UUID]--
+				pack.Wheeled = append(pack.Wheeled, c)
+			case c.UUID == ours:
+				ours = ""
+			default:
+				pack.Missing = append(pack.Missing, c)
+			}
+		}
+		recordWheels(d, *first, pack)
+	}
+	d.Packs = append(d.Packs, pack)
+}
+
+// Record a pick
+func (d *Draft) addPick(card DraftCard) {
+	d.Picks = append(d.Picks, DraftPick{Round: d.Round, Size: d.PackNum, Picked: now(), Card: card})
+}
+
+// Mark this draft as over and save it
+func (d *Draft) finish() {
+	d.Finished = true
+	d.Ended = now()
+	d.save()
+	recordDraftInLedger(d)
+}
+
+// Total value of everything we picked
+func (d *Draft) poolValue() (plat, gold int) {
+	for _, p := range d.Picks {
+		plat += p.Card.Plat
+		gold += p.Card.Gold
+	}
+	return
+}
+
+// Where this draft's log lives
+func (d *Draft) logFile() string {
+	return filepath.Join(Config["draft_log_dir"], fmt.Sprintf("draft-%v.json", d.ID))
+}
+
+// Write the draft log out. We do this after every pack and pick so a restart doesn't lose anything.
+func (d *Draft) save() {
+	if Config["draft_log_dir"] == "" {
+		return
+	}
+	if err := os.MkdirAll(Config["draft_log_dir"], 0770); err != nil {
+		fmt.Printf("Could not create draft log directory %v: %v\n", Config["draft_log_dir"], err)
+		return
+	}
+	blob, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		fmt.Printf("Could not encode draft %v: %v\n", d.ID, err)
+		return
+	}
+	if err := ioutil.WriteFile(d.logFile(), blob, 0660); err != nil {
+		fmt.Printf("Could not write draft log %v: %v\n", d.logFile(), err)
+	}
+}
+
+// Read in a single draft log
+func readDraftLog(fname string) (*Draft, error) {
+	blob, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	d := &Draft{}
+	if err := json.Unmarshal(blob, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Read in every draft log we've got, oldest first
+func readDraftLogs() []*Draft {
+	files, _ := filepath.Glob(filepath.Join(Config["draft_log_dir"], "draft-*.json"))
+	sort.Strings(files)
+	var drafts []*Draft
+	for _, fname := range files {
+		d, err := readDraftLog(fname)
+		if err != nil {
+			fmt.Printf("Could not read draft log %v: %v\n", fname, err)
+			continue
+		}
+		drafts = append(drafts, d)
+	}
+	return drafts
+}
+
+// If we were in the middle of a draft when we last shut down, pick it back up
+func restoreDraft() {
+	drafts := readDraftLogs()
+	if len(drafts) == 0 {
+		return
+	}
+	d := drafts[len(drafts)-1]
+	if d.Finished {
+		return
+	}
+	currentDraft = d
+	fmt.Printf("Resuming draft started at %v (round %v, %v picks so far)\n", d.Started.Format(time.UnixDate), d.Round, len(d.Picks))
+}
+
+// Find a draft by its ID (or the most recent one if the ID is blank)
+func findDraft(id string) *Draft {
+	drafts := readDraftLogs()
+	if len(drafts) == 0 {
+		return nil
+	}
+	if id == "" {
+		return drafts[len(drafts)-1]
+	}
+	for _, d := range drafts {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+// List the drafts we've got logs for
+func printDraftList() {
+	for _, d := range readDraftLogs() {
+		plat, gold := d.poolValue()
+		status := "in progress"
+		if d.Finished {
+			status = "finished"
+		}
+		fmt.Printf("%v: %v draft started %v with %v picks worth %vp and %vg (%v)\n", d.ID, d.Format, d.Started.Format(time.UnixDate), len(d.Picks), plat, gold, status)
+	}
+}
+
+func sprintDraftCard(c DraftCard) string {
+	return fmt.Sprintf("'[%v %2d - %3dp/%3dg] %v'", c.Rarity, c.Qty, c.Plat, c.Gold, c.Name)
+}
+
+// Step through a draft one pick at a time. Hit Enter for the next pick or 'q' to stop.
+func reviewDraft(d *Draft, in io.Reader) {
+	fmt.Printf("Reviewing %v draft %v started %v (%v picks)\n", d.Format, d.ID, d.Started.Format(time.UnixDate), len(d.Picks))
+	scanner := bufio.NewScanner(in)
+	for i, p := range d.Picks {
+		fmt.Printf("== Round %v, Pick %v [%v] at %v\n", p.Round, i+1, p.Size, p.Picked.Format(time.Kitchen))
+		if pack := d.packFor(p.Round, p.Size); pack != nil {
+			for _, c := range pack.Cards {
+				fmt.Printf("\t%v\n", sprintDraftCard(c))
+			}
+			if len(pack.Wheeled) > 0 {
+				fmt.Printf("-- WHEELED CARDS: %v\n", joinDraftCardNames(pack.Wheeled))
+			}
+			if len(pack.Missing) > 0 {
+				fmt.Printf("-- MISSING CARDS: %v\n", joinDraftCardNames(pack.Missing))
+			}
+		}
+		fmt.Printf("++ You Drafted %v\n", sprintDraftCard(p.Card))
+		if i == len(d.Picks)-1 {
+			break
+		}
+		fmt.Print("[Enter for next pick, q to quit] ")
+		if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "q" {
+			fmt.Println()
+			return
+		}
+	}
+	plat, gold := d.poolValue()
+	fmt.Printf("Pool value: %vp and %vg\n", plat, gold)
+}
+
+func joinDraftCardNames(cards []DraftCard) string {
+	names := make([]string, len(cards))
+	for i, c := range cards {
+		names[i] = fmt.Sprintf("'%v'", c.Name)
+	}
+	return strings.Join(names, ", ")
+}
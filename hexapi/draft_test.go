@@ -0,0 +1,88 @@
+// Test cases for draft session tracking
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testDraftCardJSON(uuid string) map[string]interface{} {
+	return map[string]interface{}{"Guid": map[string]interface{}{"m_Guid": uuid}}
+}
+
+func testDraftPackMessage(uuids []string) map[string]interface{} {
+	var cards []interface{}
+	for _, u := range uuids {
+		cards = append(cards, testDraftCardJSON(u))
+	}
+	return map[string]interface{}{"Message": "DraftPack", "Cards": cards}
+}
+
+func testDraftPickMessage(uuid string) map[string]interface{} {
+	return map[string]interface{}{"Message": "DraftCardPicked", "Card": testDraftCardJSON(uuid)}
+}
+
+// Set up a collection with 'n' cards in it and hand back their UUIDs
+func testDraftSetup(t *testing.T, n int) []string {
+	Config = make(map[string]string)
+	Config["draft_log_dir"] = t.TempDir()
+	currentDraft = nil
+	var uuids []string
+	for i := 0; i < n; i++ {
+		uuid := fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1)
+		cardCollection[uuid] = Card{name: fmt.Sprintf("Card %v", i), uuid: uuid, plat: i + 1, gold: (i + 1) * 100, rarity: "C"}
+		uuids = append(uuids, uuid)
+	}
+	return uuids
+}
+
+func TestDraftWheel(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[0]))
+	// Eight picks later we get the same pack back with the first nine cards still in it
+	draftPackEvent(testDraftPackMessage(uuids[1:10]))
+
+	d := currentDraft
+	if d == nil {
+		t.Fatalf("currentDraft is nil after a draft pack")
+	}
+	if len(d.Packs) != 2 || len(d.Picks) != 1 {
+		t.Fatalf("draft has %v packs and %v picks but we expected 2 and 1", len(d.Packs), len(d.Picks))
+	}
+	if d.Picks[0].Card.UUID != uuids[0] || d.Picks[0].Size != 17 {
+		t.Errorf("first pick was %v from a pack of %v but we expected %v from a pack of 17", d.Picks[0].Card.UUID, d.Picks[0].Size, uuids[0])
+	}
+	wheel := d.Packs[1]
+	if len(wheel.Wheeled) != 9 {
+		t.Errorf("%v cards wheeled but we expected 9", len(wheel.Wheeled))
+	}
+	if len(wheel.Missing) != 7 {
+		t.Errorf("%v cards went missing but we expected 7", len(wheel.Missing))
+	}
+	for _, c := range wheel.Missing {
+		if c.UUID == uuids[0] {
+			t.Errorf("our own pick %v shows up as missing", c.Name)
+		}
+	}
+}
+
+func TestDraftRestore(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[3]))
+	id := currentDraft.ID
+
+	currentDraft = nil
+	restoreDraft()
+	if currentDraft == nil || currentDraft.ID != id {
+		t.Fatalf("restoreDraft() did not pick the draft %v back up", id)
+	}
+	if len(currentDraft.Picks) != 1 || currentDraft.Picks[0].Card.UUID != uuids[3] {
+		t.Errorf("restored draft has picks %v but we expected one pick of %v", currentDraft.Picks, uuids[3])
+	}
+	if currentDraft.PackNum != 17 {
+		t.Errorf("restored draft is on pack %v but we expected 17", currentDraft.PackNum)
+	}
+}
@@ -0,0 +1,145 @@
+// Shared HTTP client for everything we pull from (or push to) remote servers
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fetcher wraps an http.Client with the timeouts, retries and headers we want on every request
+type fetcher struct {
+	client    *http.Client
+	retries   int
+	backoff   time.Duration
+	userAgent string
+}
+
+// Returned when a server answers with something other than a 2xx status code
+type httpStatusError struct {
+	url    string
+	status int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("got HTTP status %v (%v) from %v", e.status, http.StatusText(e.status), e.url)
+}
+
+// The fetcher everybody shares. Built on first use so it picks up our Config values. That first
+// use can be on any of the timers' goroutines, so only httpFetcherOnce builds it.
+var httpFetcher *fetcher
+var httpFetcherOnce sync.Once
+
+func sharedFetcher() *fetcher {
+	httpFetcherOnce.Do(func() {
+		if httpFetcher == nil {
+			httpFetcher = newFetcher()
+		}
+	})
+	return httpFetcher
+}
+
+// Build a fetcher from 'http_timeout' (seconds), 'http_retries', 'http_retry_backoff' (milliseconds)
+// and 'http_proxy'. If there's no proxy configured, we use whatever the environment says.
+func newFetcher() *fetcher {
+	timeout, err := strconv.Atoi(Config["http_timeout"])
+	if err != nil || timeout < 1 {
+		timeout = 30
+	}
+	retries, err := strconv.Atoi(Config["http_retries"])
+	if err != nil || retries < 0 {
+		retries = 0
+	}
+	backoff, err := strconv.Atoi(Config["http_retry_backoff"])
+	if err != nil || backoff < 0 {
+		backoff = 500
+	}
+	proxy := http.ProxyFromEnvironment
+	if Config["http_proxy"] != "" {
+		proxyURL, err := url.Parse(Config["http_proxy"])
+		if err != nil {
+			fmt.Printf("Could not parse http_proxy '%v': %v. Not using a proxy.\n", Config["http_proxy"], err)
+		} else {
+			proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &fetcher{
+		client: &http.Client{
+			Timeout:   time.Second * time.Duration(timeout),
+			Transport: &http.Transport{Proxy: proxy},
+		},
+		retries:   retries,
+		backoff:   time.Millisecond * time.Duration(backoff),
+		userAgent: fmt.Sprintf("hexapi/%v (%v/%v)", programVersion, programPlatform, programArch),
+	}
+}
+
+// GET 'url' and hand back the body as a string
+func (f *fetcher) get(ctx context.Context, url string) (string, error) {
+	return f.do(ctx, "GET", url, "", nil)
+}
+
+// POST 'body' to 'url' and hand back the response body as a string
+func (f *fetcher) post(ctx context.Context, url string, contentType string, body []byte) (string, error) {
+	return f.do(ctx, "POST", url, contentType, body)
+}
+
+// Send the request, retrying with a doubling backoff if we couldn't connect or the server
+// gave us a 5xx. Anything else that isn't a 2xx comes back as an httpStatusError.
+func (f *fetcher) do(ctx context.Context, method string, url string, contentType string, body []byte) (string, error) {
+	backoff := f.backoff
+	var lastErr error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			Debug(Config["debug_http"], "[fetcher] Retrying %v %v in %v (attempt %v): %v", method, url, backoff, attempt+1, lastErr)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		respBody, retry, err := f.attempt(ctx, method, url, contentType, body)
+		if err == nil {
+			return respBody, nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return "", lastErr
+}
+
+// Make a single request. The bool says whether it's worth trying again.
+func (f *fetcher) attempt(ctx context.Context, method string, url string, contentType string, body []byte) (string, bool, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return "", false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", f.userAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", true, err
+	}
+	defer resp.Body.Close()
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", true, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", resp.StatusCode >= 500, &httpStatusError{url: url, status: resp.StatusCode}
+	}
+	return string(bodyBytes), false, nil
+}
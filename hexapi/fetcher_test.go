@@ -0,0 +1,122 @@
+// Test cases for the shared HTTP fetcher
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testFetcher(retries string) *fetcher {
+	Config = make(map[string]string)
+	Config["http_retries"] = retries
+	Config["http_retry_backoff"] = "1"
+	return newFetcher()
+}
+
+func TestFetcherRetriesServerErrors(t *testing.T) {
+	// The handler runs in the server's goroutine, so count with atomic
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte("<html>Oops</html>"))
+			return
+		}
+		rw.Write([]byte("0.11\n"))
+	}))
+	defer ts.Close()
+
+	got, err := testFetcher("3").get(context.Background(), ts.URL)
+	if err != nil {
+		t.Errorf("get(%v) returned error %v but we expected none", ts.URL, err)
+	}
+	if got != "0.11\n" {
+		t.Errorf("get(%v) == %q but we expected %q", ts.URL, got, "0.11\n")
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("get(%v) made %v requests but we expected 3", ts.URL, n)
+	}
+}
+
+func TestFetcherStatusCodes(t *testing.T) {
+	for _, c := range []struct {
+		status    int
+		wantCalls int32
+	}{
+		{http.StatusNotFound, 1},
+		{http.StatusForbidden, 1},
+		{http.StatusInternalServerError, 3},
+		{http.StatusBadGateway, 3},
+	} {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			rw.WriteHeader(c.status)
+		}))
+		_, err := testFetcher("2").get(context.Background(), ts.URL)
+		ts.Close()
+		statusErr, ok := err.(*httpStatusError)
+		if !ok || statusErr.status != c.status {
+			t.Errorf("get() for status %v returned error %v but we expected an httpStatusError", c.status, err)
+		}
+		if n := atomic.LoadInt32(&calls); n != c.wantCalls {
+			t.Errorf("get() for status %v made %v requests but we expected %v", c.status, n, c.wantCalls)
+		}
+	}
+}
+
+func TestFetcherUserAgent(t *testing.T) {
+	agents := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		agents <- req.Header.Get("User-Agent")
+	}))
+	defer ts.Close()
+
+	testFetcher("0").get(context.Background(), ts.URL)
+	agent := <-agents
+	if !strings.Contains(agent, programVersion) {
+		t.Errorf("User-Agent %q does not contain program version %q", agent, programVersion)
+	}
+}
+
+func TestFetcherCancellation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	f := testFetcher("5")
+	f.backoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*50, cancel)
+	start := time.Now()
+	_, err := f.get(ctx, ts.URL)
+	if err != context.Canceled {
+		t.Errorf("get() with a cancelled context returned %v but we expected %v", err, context.Canceled)
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Errorf("get() with a cancelled context took %v to return", time.Since(start))
+	}
+}
+
+func TestSharedFetcherConcurrentFirstUse(t *testing.T) {
+	httpFetcher = nil
+	httpFetcherOnce = sync.Once{}
+	fetchers := make(chan *fetcher, 10)
+	for i := 0; i < cap(fetchers); i++ {
+		go func() { fetchers <- sharedFetcher() }()
+	}
+	first := <-fetchers
+	for i := 1; i < cap(fetchers); i++ {
+		if f := <-fetchers; f != first || f == nil {
+			t.Fatalf("sharedFetcher() handed out %p and %p but we expected one fetcher", first, f)
+		}
+	}
+}
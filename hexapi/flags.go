@@ -0,0 +1,91 @@
+// Bitflag decoding for the State, Collection and Attributes values in CardUpdated messages
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flagTable names the bits of a bitflag value. bits[i] is the name for the value 1<<i, and any bit
+// past the end of the table (or with a blank name) is undocumented and shows up as "bit N".
+type flagTable struct {
+	none string
+	bits []string
+}
+
+// Card states, from the table at the top of hexapi.go
+var cardStateFlags = flagTable{none: "None", bits: cardStates}
+
+// Zones, from the Collection table at the top of hexapi.go. The table calls 0 None, but it's what we
+// get for our own champion, so that's what we call it.
+var cardZoneFlags = flagTable{none: "Champion", bits: []string{
+	"Deck",
+	"Hand",
+	"Champion",
+	"Play",
+	"Crypt",
+	"Void",
+	"Shard",
+	"Chain",
+	"Underground",
+	"Choose Effect",
+	"Mod",
+}}
+
+// Keywords, from the Card Attributes table at the top of hexapi.go
+var cardAttributeFlags = flagTable{bits: cardAttributes}
+
+// The name for a single bit
+func (t flagTable) bitName(bit uint) string {
+	if int(bit) < len(t.bits) && t.bits[bit] != "" {
+		return t.bits[bit]
+	}
+	return fmt.Sprintf("bit %v", bit)
+}
+
+// The names of every bit set in 'value', lowest bit first
+func (t flagTable) decode(value int) []string {
+	var names []string
+	for bit := uint(0); bit < 63 && value>>bit != 0; bit++ {
+		if value&(1<<bit) != 0 {
+			names = append(names, t.bitName(bit))
+		}
+	}
+	return names
+}
+
+// Turn names back into a value. Undocumented bits can be given as "bit N".
+func (t flagTable) encode(names []string) (int, error) {
+	value := 0
+	for _, name := range names {
+		found := false
+		for bit, b := range t.bits {
+			if b != "" && strings.EqualFold(b, name) {
+				value |= 1 << uint(bit)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		if strings.HasPrefix(name, "bit ") {
+			if bit, err := strconv.Atoi(strings.TrimPrefix(name, "bit ")); err == nil && bit >= 0 && bit < 63 {
+				value |= 1 << uint(bit)
+				continue
+			}
+		}
+		return 0, fmt.Errorf("'%v' is not a flag we know about", name)
+	}
+	return value, nil
+}
+
+// The names of every bit set in 'value' joined up with commas
+func (t flagTable) sprint(value int) string {
+	if value == 0 {
+		return t.none
+	}
+	return strings.Join(t.decode(value), ", ")
+}
@@ -0,0 +1,128 @@
+// Test cases for bitflag decoding
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Pull the documented bits for a table out of the comments at the top of hexapi.go. Tables start with
+// a line with their title and end at the first blank comment line (or the first line that isn't a comment).
+func documentedBits(t *testing.T, title string, line *regexp.Regexp, bitIndex bool) []uint {
+	in, err := os.Open("hexapi.go")
+	if err != nil {
+		t.Fatalf("Could not open hexapi.go: %v", err)
+	}
+	defer in.Close()
+	var bits []uint
+	inTable := false
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if !inTable {
+			inTable = strings.Contains(text, title)
+			continue
+		}
+		if !strings.HasPrefix(text, "//") || strings.TrimSpace(text) == "//" {
+			break
+		}
+		result := line.FindStringSubmatch(text)
+		if len(result) == 0 {
+			continue
+		}
+		n, _ := strconv.Atoi(result[1])
+		if bitIndex {
+			bits = append(bits, uint(n))
+		} else if n > 0 {
+			bits = append(bits, uint(intLog2Exact(t, n)))
+		}
+	}
+	if len(bits) == 0 {
+		t.Fatalf("Could not find the '%v' table in hexapi.go", title)
+	}
+	return bits
+}
+
+func intLog2Exact(t *testing.T, n int) int {
+	for bit := 0; bit < 63; bit++ {
+		if n == 1<<uint(bit) {
+			return bit
+		}
+	}
+	t.Fatalf("%v is documented as a flag but isn't a power of two", n)
+	return 0
+}
+
+func TestFlagTablesMatchComments(t *testing.T) {
+	for _, f := range []struct {
+		name     string
+		table    flagTable
+		title    string
+		line     *regexp.Regexp
+		bitIndex bool
+	}{
+		{"states", cardStateFlags, "Card States", regexp.MustCompile(`^//\s+\d*\s*-\s*(\d+)\s*-\s*\S`), true},
+		{"attributes", cardAttributeFlags, "Card Attributes", regexp.MustCompile(`^//\s+\d*\s*-\s*(\d+)\s*-\s*\S`), true},
+		{"zones", cardZoneFlags, "Collection is a power of 2 map", regexp.MustCompile(`^//\s+(\d+) - \S`), false},
+	} {
+		for _, bit := range documentedBits(t, f.title, f.line, f.bitIndex) {
+			value := 1 << bit
+			names := f.table.decode(value)
+			if len(names) != 1 || strings.HasPrefix(names[0], "bit ") {
+				t.Errorf("%v bit %v is documented but decodes to %v", f.name, bit, names)
+				continue
+			}
+			got, err := f.table.encode(names)
+			if err != nil || got != value {
+				t.Errorf("%v: encode(decode(%v)) == %v (%v)", f.name, value, got, err)
+			}
+		}
+	}
+}
+
+func TestFlagDecoding(t *testing.T) {
+	for _, f := range []struct {
+		table flagTable
+		value int
+		want  string
+	}{
+		{cardStateFlags, 0, "None"},
+		{cardStateFlags, 2, "exhausted"},
+		// These used to come out wrong since decoding started at 15 instead of the top bit
+		{cardStateFlags, 16, "damaged"},
+		{cardStateFlags, 16 + 8192, "damaged, came out this turn"},
+		{cardStateFlags, 16384, "started the turn on your side"},
+		{cardZoneFlags, 0, "Champion"},
+		{cardZoneFlags, 8, "Play"},
+		{cardZoneFlags, 8 + 128, "Play, Chain"},
+		{cardZoneFlags, 4096, "bit 12"},
+		{cardAttributeFlags, 2 + 1<<31, "Flight, bit 31"},
+	} {
+		got := f.table.sprint(f.value)
+		if got != f.want {
+			t.Errorf("sprint(%v) == %q but we expected %q", f.value, got, f.want)
+		}
+		if f.value == 0 {
+			continue
+		}
+		back, err := f.table.encode(f.table.decode(f.value))
+		if err != nil || back != f.value {
+			t.Errorf("encode(decode(%v)) == %v (%v) but we expected it back", f.value, back, err)
+		}
+	}
+	if _, err := cardZoneFlags.encode([]string{"Graveyard"}); err == nil {
+		t.Errorf("encode() took a zone that doesn't exist")
+	}
+	if got := translateCardState(16 + 4); got != "blocking, damaged" {
+		t.Errorf("translateCardState(20) == %q but we expected %q", got, "blocking, damaged")
+	}
+	if !reflect.DeepEqual(attributeKeywords(1<<27), []string{"Lethal"}) {
+		t.Errorf("attributeKeywords(1<<27) == %v but we expected Lethal", attributeKeywords(1<<27))
+	}
+}
@@ -48,6 +48,8 @@ package main
 //  - Make Tournament update messages while in tournament less chatty and more informative
 //  - Add query param when checking for version number for version tracking
 //  - Figure out why the first card of a draft pack prints twice and fix that
+//  + Keep a local card database so we don't have to look up card names remotely
+//  + Keep a log of each draft so we can resume after a restart and review drafts later
 //
 //  TODO: I added "type" output in the JSON price output.  Use that to create cards and make their nature follow whatever the type is
 //  TODO: Find out the reason why the 'nature' variable keeps getting unset for Cards.
@@ -56,12 +58,12 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	//"github.com/d4l3k/go-pry/pry"
 	"io/ioutil"
 	"log"
-	"math"
 	"net/http"
 	"os"
 	"reflect"
@@ -75,15 +77,26 @@ import (
 
 // Card The Cards we work with and all the info we need about them
 type Card struct {
-	name   string
-	uuid   string
-	qty    int
-	eaqty  int
-	rarity string
-	gold   int
-	plat   int
-	wiw    [18]int
-	nature string // possible types are "Card", "Equipment", "Champion", etc.
+	name        string
+	uuid        string
+	qty         int
+	eaqty       int
+	rarity      string
+	gold        int
+	plat        int
+	goldGuessed bool // The price feed didn't have a gold price, so 'gold' is made up from 'plat' (or is just 1)
+	platGuessed bool // Same for plat
+	wiw         [18]int
+	nature      string // possible types are "Card", "Equipment", "Champion", etc.
+	shard       string // "Ruby", "Wild", "Ruby, Wild", etc.
+	cost        int
+	costKnown   bool // Cost 0 is a real cost, so we need to know whether we've been told one
+	atk         int
+	def         int
+	cardType    string // "Troop", "Action", etc.
+	subtype     string // "Yeti", "Human Warrior", etc.
+	set         string
+	fullRarity  string // "Legendary", "Rare", etc. 'rarity' is just the first letter of this
 }
 
 // Player variable that we'll be using in tracking game state
@@ -110,6 +123,9 @@ type gameCard struct {
 	name       string
 	state      int
 	location   int
+	uuid       string
+	attrs      int
+	shards     string
 }
 
 // Game variable to track game state
@@ -156,25 +172,23 @@ var ntum = make(map[string]string)
 // Configuration values we'll use all around
 var Config = make(map[string]string)
 
+// The clock and the pause we use while handling events. The draft simulator swaps these out so
+// recorded messages play back the same way every time.
+var now = time.Now
+var sleep = time.Sleep
+
 // And some general variables we'll use to keep track of things
 var GameStartTime = time.Now()
 
-var packValue int
-var packGoldValue int
 var collectionGoldValue = 0
 var collectionPlatValue = 0
 var packCost int
 var packGoldCost int
 var goldPlatRatio int // How many gold for a single plat
-var packNum int
 var packSize = 17
-var packContents [18]string
-var previousContents [18]string
-var draftCardsPicked = make(map[string]int)
 var sessionPlatProfit int
 var sessionGoldProfit int
 var lastAPIMessage string
-var lastDraftPack string
 
 // var matches []interface{}
 // var players []interface{}
@@ -196,28 +210,10 @@ var priceRefreshCacheTimer *time.Timer
 var collectionTimerPeriod = time.Second * time.Duration(20)
 var collectionCacheTimer *time.Timer
 
-// UUID to name lookup happens 1 minute after the last lookup was attempted
+// UUID to name lookup happens 1 minute after a UUID is queued. Failed lookups back off from there.
 var nameLookupTimerPeriod = time.Minute * time.Duration(1)
 var nameLookupCacheTimer *time.Timer
 
-// An array we use to keep track of UUIDs we need to look up names for
-var UUIDsToLookup []string
-
-// List of locations cards can be for CardUpdated events
-var cardCollectionMap = map[int]string{
-	0:   "Champion",
-	1:   "Deck",
-	2:   "Hand",
-	4:   "Opposing Champion",
-	8:   "Play",
-	16:  "Discard",
-	32:  "Void",
-	64:  "Shard",
-	128: "Chain",
-	256: "Tunnelled",
-	512: "Choose Effect",
-}
-
 var ladderDivisionLookup = []string{"Bronze", "Silver", "Gold", "Platinum", "Cosmic"}
 
 // FUNCTIONS
@@ -343,12 +339,15 @@ func cardUpdatedEvent(f map[string]interface{}) {
 	if name == "" {
 		return
 	}
+	gameMutex.Lock()
+	defer gameMutex.Unlock()
+	learnCardNameFromJSON(f)
+	applyCardUpdatedMetadata(f)
 	atk := floatToInt(f["Attack"].(float64))
 	def := floatToInt(f["Defense"].(float64))
 	cost := floatToInt(f["Cost"].(float64))
 	state := floatToInt(f["State"].(float64))
 	shards := f["Shards"]
-	attrs := f["Attributes"]
 	stats := fmt.Sprintf("[(%v/%v) for %v]", atk, def, cost)
 	collection, _ := strconv.Atoi(fmt.Sprintf("%v", f["Collection"]))
 	controller := floatToInt(f["Controller"].(float64))
@@ -362,29 +361,34 @@ func cardUpdatedEvent(f map[string]interface{}) {
 		player = &currentGame.p2
 	} else if currentGame.p2.id == 2 {
 		// See if this player is config'd and figure out if it's p1 or p2 and set it appropriately if need be
-		_ = checkPlayerConfigured(controller)
+		player = checkPlayerConfigured(f["Controller"])
 	} else {
 		fmt.Printf("Could not find player with controller number of %v\n", controller)
 		showGameState()
 		return
 	}
+	// Keep track of where every card is so we can show the whole board
+	gc := gameCardFromJSON(f, collection)
+	key := player.cardInstanceKey(f, collection)
+	old, seen := player.updateCard(key, gc)
+	if seen && old.location == gc.location && Config["show_keyword_changes"] == "true" {
+		if changes := sprintKeywordChanges(name, old.attrs, gc.attrs); changes != "" {
+			fmt.Printf("\tKEYWORDS: %v's %v\n", player.name, changes)
+		}
+	}
+	noteTurnCard(player, old, seen, gc)
+	noteReveal(player, key, gc)
+	// With turn summaries on, we print what happened at the end of each turn instead of as it happens
+	quiet := turnSummaries()
 
 	// fmt.Printf("Current Player Champion name: %v\n", player.champion.name)
 	// Do a thing here to match this message with a card we know is in the game already.  Based on that, we can
 	// either create a new card or update the old card
 
-	switch collection {
-	case 1: // Deck Zone
-		{
-			fmt.Printf("\tDECK: %v's %v was sent back into their deck\n", player.name, name)
-			return
-		}
-	case 2: // Hand Zone
-		{
-			fmt.Printf("\tHAND: %v's %v went into their hand\n", player.name, name)
-			return
-		}
-	case 4: // Champion Zone
+	// Collection is a set of zone bits, so a card can be in more than one at once. We go with the
+	// zone that matters most: the champion, then the chain, play and on down to the deck.
+	switch {
+	case collection&4 != 0: // Champion Zone
 		{
 			if Config["debug_cardUpdated"] == "true" {
 				fmt.Printf("CCC= Working on %v with def %v, state %v and collection %v for player %v\n", name, def, translateCardState(state), collection, player)
@@ -398,6 +402,7 @@ func cardUpdatedEvent(f map[string]interface{}) {
 			}
 			if player.champion.name == "Unknown" {
 				player.champion.name = name
+				recordHealth(name, def)
 				return
 			}
 			champ := player.champion
@@ -411,10 +416,16 @@ func cardUpdatedEvent(f map[string]interface{}) {
 				if difference < 0 {
 					difference = difference * -1
 				}
-				fmt.Printf("CHAMP: %v %v %v health and now has %v health\n", name, modification, difference, def)
+				if !quiet {
+					fmt.Printf("CHAMP: %v %v %v health and now has %v health\n", name, modification, difference, def)
+				}
+				if def < champ.def {
+					noteTurnDamage(player, difference)
+				}
 				// fmt.Printf("health %v and state %v\n", def, translateCardState(state))
 				// fmt.Printf("Champion %v now has health %v and state %v\n", name, def, state)
 				player.champion.def = def
+				recordHealth(name, def)
 				// TODO: Once we have a better idea about the states, pull this out into it's own block
 				player.champion.state = state
 			} else {
@@ -422,41 +433,67 @@ func cardUpdatedEvent(f map[string]interface{}) {
 			}
 		}
 		return
-	case 8:
+	case collection&128 != 0:
 		{
-			if Config["show_battle_details"] == "true" {
-				fmt.Printf("\tBATTLE: %v's %v [%v/%v] state: %v; shards: %v; attrs: %v\n", player.name, name, atk, def, translateCardState(state), shards, attrs)
+			if !quiet {
+				fmt.Printf("\tCHAIN: %v played %v\n", player.name, name)
 			}
 			return
 		}
-	case 16:
+	case collection&8 != 0:
 		{
-			fmt.Printf("\tCRYPT: %v's %v was sent to their crypt\n", player.name, name)
+			if Config["show_battle_details"] == "true" && !quiet {
+				fmt.Printf("\tBATTLE: %v's %v [%v/%v] state: %v; shards: %v; attrs: %v\n", player.name, name, atk, def, translateCardState(state), shards, strings.Join(attributeKeywords(gc.attrs), ", "))
+			}
+			return
+		}
+	case collection&64 != 0:
+		{
+			if !quiet {
+				fmt.Printf("\tSHARD: %v played %v as a resource\n", player.name, name)
+			}
+			return
+		}
+	case collection&16 != 0:
+		{
+			if !quiet {
+				fmt.Printf("\tCRYPT: %v's %v was sent to their crypt\n", player.name, name)
+			}
 			return
 		}
-	case 32:
+	case collection&32 != 0:
 		{
-			fmt.Printf("\tVOID: %v's %v was sent to the void\n", player.name, name)
+			if !quiet {
+				fmt.Printf("\tVOID: %v's %v was sent to the void\n", player.name, name)
+			}
 			return
 		}
-	case 64:
+	case collection&256 != 0:
 		{
-			fmt.Printf("\tSHARD: %v played %v as a resource\n", player.name, name)
+			if !quiet {
+				fmt.Printf("\tUNDERGROUND: %v tunnelled %v\n", player.name, name)
+			}
 			return
 		}
-	case 128:
+	case collection&2 != 0: // Hand Zone
 		{
-			fmt.Printf("\tCHAIN: %v played %v\n", player.name, name)
+			if !quiet {
+				fmt.Printf("\tHAND: %v's %v went into their hand\n", player.name, name)
+			}
 			return
 		}
-	case 256:
+	case collection&1 != 0: // Deck Zone
 		{
-			fmt.Printf("\tUNDERGROUND: %v tunnelled %v\n", player.name, name)
+			if !quiet {
+				fmt.Printf("\tDECK: %v's %v was sent back into their deck\n", player.name, name)
+			}
 			return
 		}
 	default:
 		{
-			fmt.Printf("In %v Zone:\t'%v' %v\n", cardCollectionMap[collection], name, stats)
+			if !quiet {
+				fmt.Printf("In %v Zone:\t'%v' %v\n", cardZoneFlags.sprint(collection), name, stats)
+			}
 			return
 		}
 	}
@@ -473,51 +510,7 @@ func cardUpdatedEvent(f map[string]interface{}) {
 }
 
 func translateCardState(state int) string {
-	// Init this to an empty string
-	returnString := ""
-	// Shortcut for state of 0
-	if state == 0 {
-		return "None"
-	}
-	// Set this to the top value we'd want to be looking at which is 2^(length of CardStates)
-	checkingStateValue := len(cardStates)
-
-	// Count down the possible states and, if it's there, add it to the return string
-	for true {
-		if state >= checkingStateValue {
-			// Figure out the index of the cardStates array we need to refer to
-			index := intLog2(checkingStateValue)
-			// If the index is 0, go ahead and return the returnString
-			if index <= 0 {
-				return returnString
-			}
-			// Otherwise, go ahead and process things
-			if Config["debug_card_states"] == "true" {
-				fmt.Printf("**** Index: %v from checkingStateValue %v\n", index, checkingStateValue)
-			}
-			// Some checking here so we can have nice formatting
-			if returnString == "" {
-				returnString = cardStates[index]
-			} else {
-				returnString = fmt.Sprintf("%v, %v", returnString, cardStates[index])
-			}
-			// And, remove the current checkingStateValue from the state so we count down
-			state -= checkingStateValue
-		}
-		checkingStateValue = checkingStateValue / 2
-	}
-	return returnString
-}
-
-// Do an integer version of math.Log2.  Make sure it *IS* a power of two, then
-func intLog2(num int) int {
-	floatNum := float64(num)
-	power := math.Log2(floatNum)
-	if math.Mod(power, 1.0) != 0.0 {
-		power, _ = math.Modf(power)
-	}
-	intPower := int(power)
-	return intPower
+	return cardStateFlags.sprint(state)
 }
 
 // Dump out current game state. For debugging. Shouldn't see this in normal operations
@@ -565,22 +558,7 @@ func (c *Card) directlySetCardCount(i int) {
 }
 func changeCardCount(uuid string, i int) {
 	// fmt.Println("Inside changeCardCount()")
-	// If we're currently drafting, we want to skip this check (it'll all come out in the wash later, but
-	// for now we want these counts to go up so they're accurately reflected in the selection output)
-	if !currentlyDrafting {
-		// Check to see if we've got an entry in draftCardsPicked we need to account for
-		if _, ok := draftCardsPicked[uuid]; ok {
-			if draftCardsPicked[uuid] > 0 {
-				// c := cardCollection[uuid]
-				// fmt.Printf("INFO: Not incrementing %v because it's on the draft list %v times\n", c.name, draftCardsPicked[uuid])
-				decrementDraftCardsPicked(uuid)
-				// fmt.Println("About to return from inside changeCardCount")
-				return
-			}
-		}
-	}
-	// fmt.Println("No draft cards for this type. Handing off to rawChangeCardCount")
-	// if not, go ahead and call the dangerous function
+	// Drafted cards are matched up with their Collection updates in reconcilePickUpdate() before we get here
 	c := cardCollection[uuid]
 	// q := c.qty + i
 	Debug(Config["debug_collection_update"], fmt.Sprintf("[changeCardCount] Changing qty for '%v' by %v (old qty %v)", c.name, i, c.qty))
@@ -607,6 +585,7 @@ func rawChangeCardCount(uuid string, i int) {
 		c.objRawChangeCardCount(i)
 		Debug(Config["debug_collection_update"], fmt.Sprintf("[rawChangeCardCount] Card Quantity after change: %v", c.qty))
 		cardCollection[uuid] = c
+		auditQtyChange(uuid, "qty", c.qty-i, c.qty)
 		nc := cardCollection[uuid]
 		Debug(Config["debug_collection_update"], fmt.Sprintf("[rawChangeCardCount] Card Quantity after re-retrieving card info: %v", nc.qty))
 
@@ -644,6 +623,7 @@ func rawChangeEACardCount(uuid string, i int) {
 		c := cardCollection[uuid]
 		c.eaqty += i
 		cardCollection[uuid] = c
+		auditQtyChange(uuid, "eaqty", c.eaqty-i, c.eaqty)
 		nc := cardCollection[uuid]
 		if (loadingCacheOrPriceData == false && Config["show_collection_quantity_changes"] == "true") || Config["debug_collection_update"] == "true" {
 			Debug("true", fmt.Sprintf("[rawChangeEACardCount] New collection EA qty for '%v' is %v (modified by %v)", nc.name, nc.eaqty, i))
@@ -655,30 +635,6 @@ func rawChangeEACardCount(uuid string, i int) {
 	}
 }
 
-func incrementDraftCardsPicked(uuid string) {
-	changeDraftCardsCount(uuid, 1)
-}
-func decrementDraftCardsPicked(uuid string) {
-	changeDraftCardsCount(uuid, -1)
-}
-func changeDraftCardsCount(uuid string, i int) {
-	if _, ok := draftCardsPicked[uuid]; ok {
-		// fmt.Printf("INFO: Bumping %v by %v for total of %v\n", uuid, i, draftCardsPicked[uuid])
-		draftCardsPicked[uuid] += i
-	} else {
-		// fmt.Printf("INFO: Adding %v to map and setting value to 1\n", uuid)
-		draftCardsPicked[uuid] = 0
-		draftCardsPicked[uuid] += i
-	}
-	// Make sure we don't go negative
-	if draftCardsPicked[uuid] < 0 {
-		// fmt.Printf("INFO: PREVENTING us from going negative for UUID %v\n", uuid)
-		draftCardsPicked[uuid] = 0
-	}
-	// Call the rawChangeCardCount function here so we have one stop shopping for Draft func calls
-	// rawChangeCardCount(uuid, i)
-}
-
 // setNature ... set the nature of this card
 func (c *Card) setNature(n string) {
 	c.nature = n
@@ -701,6 +657,9 @@ func translateCardNature(n string) string {
 		return "Inventory"
 	case "Card":
 		return "Card"
+	// The price feed sometimes gives us the card's type rather than "Card"
+	case "Troop", "Action", "Constant", "Resource", "Artifact":
+		return "Card"
 	case "Mercenary":
 		return "Mercenary"
 	default:
@@ -717,7 +676,10 @@ func checkProgramVersion() {
 		return
 	}
 	versionURL := Config["version_url"]
-	body, err := grabFromURL(versionURL)
+	// Knowing about a new version isn't worth holding up startup for, so don't wait around for retries
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(10))
+	defer cancel()
+	body, err := grabFromURL(ctx, versionURL)
 	if err != nil {
 		fmt.Printf("Could not retrive version information from version url: '%v'. Encountered the following error: %v\n", versionURL, err)
 		return
@@ -773,7 +735,7 @@ func (c *Card) InfoWithWheelInfo(place int) string {
 		return fmt.Sprintf("'[%v] %v' %v {nature: %v} [Qty: %v (%v EA)] - %vp and %vg", c.rarity, c.name, c.uuid, c.nature, c.qty, c.eaqty, c.plat, c.gold)
 		// return fmt.Sprintf("'[%v] %v' %v [Qty: %v (%v EA)] - %vp and %vg %3d%%", c.rarity, c.name, c.uuid, c.qty, c.eaqty, c.plat, c.gold, c.wiw[place])
 	}
-	return fmt.Sprintf("'%v' [Qty: %v (%v EA)] - %vp and %vg - %3d%% likely to wheel", c.name, c.qty, c.eaqty, c.plat, c.gold, c.wiw[place])
+	return fmt.Sprintf("'%v' [Qty: %v (%v EA)] - %vp and %vg - %v likely to wheel", c.name, c.qty, c.eaqty, c.plat, c.gold, sprintWheelChance(*c, place))
 }
 
 func getCardInfoWithWheelInfo(c Card, place int) string {
@@ -787,7 +749,7 @@ func getCardInfoWithWheelInfo(c Card, place int) string {
 		return fmt.Sprintf("'[%v] %v' %v {nature: %v} [Qty: %v (%v EA)] - %vp and %vg", c.rarity, c.name, c.uuid, c.nature, c.qty, c.eaqty, c.plat, c.gold)
 		// return fmt.Sprintf("'[%v] %v' %v [Qty: %v (%v EA)] - %vp and %vg %3d%%", c.rarity, c.name, c.uuid, c.qty, c.eaqty, c.plat, c.gold, c.wiw[place])
 	}
-	return fmt.Sprintf("'%v' [Qty: %v (%v EA)] - %vp and %vg - %3d%% likely to wheel", c.name, c.qty, c.eaqty, c.plat, c.gold, c.wiw[place])
+	return fmt.Sprintf("'%v' [Qty: %v (%v EA)] - %vp and %vg - %v likely to wheel", c.name, c.qty, c.eaqty, c.plat, c.gold, sprintWheelChance(c, place))
 }
 
 func getCardCount(uuid string) int {
@@ -816,109 +778,74 @@ func getCardNameFromUUID(uuid string) string {
 		c := cardCollection[uuid]
 		name = c.name
 	}
+	// Next, check the local card database. Cards we haven't named yet are in the collection with
+	// their UUID as their name, so those count as not found too.
+	if name == "" || name == uuid {
+		name, _ = lookupCardDB(uuid)
+	}
 	// If that didn't work, make note of the UUID and set a timer so we can look it up later
-	if name == "" {
+	if name == "" || name == uuid {
 		addRemoteNameLookup(uuid)
 		name = uuid
 	}
 	return name
 }
 
-func addRemoteNameLookup(uuid string) {
-	// fmt.Printf("Adding UUID %v to lookup array\n", uuid)
-	// Add the uuid to the UUIDs to look up
-	UUIDsToLookup = append(UUIDsToLookup, uuid)
-	// And set a timer to go off
-	if nameLookupCacheTimer != nil {
-		nameLookupCacheTimer.Stop()
-	}
-	nameLookupCacheTimer = time.AfterFunc(nameLookupTimerPeriod, doRemoteNameLookup)
-}
-
-func doRemoteNameLookup() {
-	// fmt.Printf("INFO: Beginning doRemoteNameLookup\n")
-	// If the CacheTimer's initialized, go ahead and stop it for the duration of our lookups
-	if nameLookupCacheTimer != nil {
-		nameLookupCacheTimer.Stop()
-	}
-
-	var newUUIDList []string
-	// Iterate through the list to see if we can look up the name
-	for _, uuid := range UUIDsToLookup {
-		uuidToNameURL := fmt.Sprintf("http://doc-x.net/hex/uuid_to_name.rb?%v", uuid)
-		gotHTTPError := false
-		name := ""
-		var body string
-		var err error
-		// fmt.Printf("Retrieving Name for UUID %v\n", uuid)
-		body, err = grabFromURL(uuidToNameURL)
-		if err != nil {
-			gotHTTPError = true
-		}
-		// If we had a problem AND we're not simply doing an update, then exit.
-		// Othwerise, continue on and we'll just be using (possibly) stale data
-		if gotHTTPError {
-			// fmt.Printf("Encountered error trying to get name for UUID %v", uuid)
-			newUUIDList = append(newUUIDList, uuid)
-		} else {
-			// Take the name we got and update the cardCollection with that info
-			name = strings.TrimSpace(body)
-			c := cardCollection[uuid]
-			c.name = name
-			cardCollection[uuid] = c
-		}
-	}
-	// Replace UUIDsToLookup with newUUIDList
-	UUIDsToLookup = newUUIDList
-	// And set up a timer to go through this whole thing again if we need to look up any more names
-	if len(UUIDsToLookup) > 0 {
-		nameLookupCacheTimer = time.AfterFunc(nameLookupTimerPeriod, doRemoteNameLookup)
-	}
-}
-
 // Handle picking of Draft Cards
 // Immediately increment the count of the card, but also keep track of this
 // so we can adjust later when the Collection Update events come in
 func draftCardPickedEvent(f map[string]interface{}) {
 	// Make sure we know we're drafting
 	currentlyDrafting = true
+	d := draftForPick()
 	card := f["Card"].(map[string]interface{})
 	uuid := getCardUUIDFromJSON(card)
+	// Snapshot the card before we bump the count so the draft log shows what we had when we picked it
+	d.addPick(draftCardFromCollection(uuid, packToWheelNumber(d.PackNum)))
+	queueDraftUpload(d, d.Picks[len(d.Picks)-1])
+	setAuditSource("draft pick", card)
 	incrementCardCount(uuid)
-	incrementDraftCardsPicked(uuid)
+	setAuditSource("unknown", nil)
+	addPendingPick(uuid, d.ID)
 	c := cardCollection[uuid]
 	info := getCardInfo(c)
 	// Print out information to user
-	fmt.Printf("++ Pack [%v]: You Drafted %v\n", packNum, info)
+	fmt.Printf("++ Pack [%v]: You Drafted %v\n", d.PackNum, info)
 	if Config["debug_pack_value"] == "true" {
-		fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Adding %v to current pack value of %v (should total %v)\n", c.plat, packValue, c.plat+packValue)
+		fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Adding %v to current pack value of %v (should total %v)\n", c.plat, d.PackValue, c.plat+d.PackValue)
 	}
 
-	packValue += c.plat
-	packGoldValue += c.gold
+	d.PackValue += c.plat
+	d.PackGoldValue += c.gold
 	// Put something here to remove c.name from packContents[packNum]
-	if packNum > 8 {
+	if d.PackNum > 8 {
 		prevCard := fmt.Sprintf("'%v', ", c.name)
-		packContents[packNum] = strings.Replace(packContents[packNum], prevCard, "", 1)
+		d.PackContents[d.PackNum] = strings.Replace(d.PackContents[d.PackNum], prevCard, "", 1)
 	}
-	if packNum == 1 {
+	if d.PackNum == 1 {
 		if Config["debug_pack_value"] == "true" {
 			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session Plat profit prior to modification: %v\n", sessionPlatProfit)
 			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session Gold profit prior to modification: %v\n", sessionGoldProfit)
 		}
-		packProfit := packValue - packCost
-		packGoldProfit := packGoldValue - packGoldCost
+		packProfit := d.PackValue - packCost
+		packGoldProfit := d.PackGoldValue - packGoldCost
 		sessionPlatProfit += packProfit
 		sessionGoldProfit += packGoldProfit
 		if Config["debug_pack_value"] == "true" {
-			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session profit after modification: %v (pack value of %v and pack cost of %v)\n", sessionPlatProfit, packValue, packCost)
-			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session profit after modification: %v (pack value of %v and pack cost of %v)\n", sessionGoldProfit, packGoldValue, packGoldCost)
+			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session profit after modification: %v (pack value of %v and pack cost of %v)\n", sessionPlatProfit, d.PackValue, packCost)
+			fmt.Printf("==== DEBUG: [DraftCardPickedEvent] Session profit after modification: %v (pack value of %v and pack cost of %v)\n", sessionGoldProfit, d.PackGoldValue, packGoldCost)
 		}
 		fmt.Println("==========================    PACK AND SESSION STATISTICS    ==========================")
-		fmt.Printf("Total pack value: %v plat (%v gold). Pack profit is %vp (%vg) and total session profit is %vp (%vg).\n", packValue, packGoldValue, packProfit, packGoldProfit, sessionPlatProfit, sessionGoldProfit)
+		fmt.Printf("Total pack value: %v plat (%v gold). Pack profit is %vp (%vg) and total session profit is %vp (%vg).\n", d.PackValue, d.PackGoldValue, packProfit, packGoldProfit, sessionPlatProfit, sessionGoldProfit)
 		fmt.Println("==========================    PACK AND SESSION STATISTICS    ==========================")
 
+		// If that was the last pick of the last round, the draft's over
+		if d.Round >= d.Rounds {
+			d.finish()
+			d.writeRecap()
+		}
 	}
+	d.save()
 	// And unset this in case we're done
 	currentlyDrafting = false
 }
@@ -946,109 +873,93 @@ func packToWheelNumber(pack int) int {
 
 // Process draft pack choices
 func draftPackEvent(f map[string]interface{}) {
-	haveLeastOf := Card{name: "bogusvalue"}
-	worthMostGold := Card{name: "bogusvalue"}
-	worthMostPlat := Card{name: "bogusvalue"}
+	var packCards []Card
 	cards, _ := f["Cards"].([]interface{})
 	cardsString := fmt.Sprintf("%v", cards)
-	uuids := ""
 	numCards := len(cards)
 	var wheelPackNum int
 	// For some reason we're getting DraftPack messages with zero cards
 	if numCards == 0 {
 		return
 	}
+	// Do a check to see if we've seen this message before
+	if currentDraft != nil && currentDraft.LastPack == cardsString {
+		if Config["debug_duplicate_draftpack"] == "true" {
+			fmt.Println("DEBUG: Duplicate Draft Pack. Discarding.")
+			fmt.Printf("DEBUG cards: >>>>%s<<<<<\n", cardsString)
+		}
+		return
+	}
+	format, _ := f["Format"].(string)
+	d := draftForPack(numCards, format)
+	d.LastPack = cardsString
 	// Figure out the wheelPackNum so we can do some stuff with it later....
 	wheelPackNum = packToWheelNumber(numCards)
 
 	// We need this for stuff when the DraftCard event fires
-	packNum = numCards
+	d.PackNum = numCards
 	// reset the pack value for a new pack along with all the pack tracking arrays
 	if numCards == packSize {
-		packValue = 0
-		for n := range packContents {
-			packContents[n] = ""
-			previousContents[n] = ""
-		}
-	}
-	// Do a check to see if we've seen this message before
-	if lastDraftPack == cardsString {
-		if Config["debug_duplicate_draftpack"] == "true" {
-			fmt.Println("DEBUG: Duplicate Draft Pack. Discarding.")
-			fmt.Printf("DEBUG cards: >>>>%s<<<<<\n", cardsString)
+		d.PackValue = 0
+		d.PackGoldValue = 0
+		for n := range d.PackContents {
+			d.PackContents[n] = ""
+			d.PreviousContents[n] = ""
 		}
-		return
 	}
+	pack := DraftPack{Round: d.Round, Size: numCards, Seen: now()}
 
 	// If we've gone through 7 or more packs, copy the previous pack contents to this pack's
 	// contents so we can figure out what's missing
 	if numCards < (packSize - 7) {
 		prevNum := numCards + 8
-		previousContents[numCards] = packContents[prevNum]
+		d.PreviousContents[numCards] = d.PackContents[prevNum]
 	}
 	// Do some computations to figure out the optimal picks for plat, gold and filling out our collection
 	contentsInfo := ""
 	for _, u := range cards {
 		card := u.(map[string]interface{})
+		learnCardNameFromJSON(card)
 		uuid := getCardUUIDFromJSON(card)
 		c := cardCollection[uuid]
-		// If this is the first time through, these will be nil
-		if haveLeastOf.name == "bogusvalue" {
-			haveLeastOf = c
-		}
-		if worthMostGold.name == "bogusvalue" {
-			worthMostGold = c
-		}
-		if worthMostPlat.name == "bogusvalue" {
-			worthMostPlat = c
-		}
-		haveLeastOf = leastQty(haveLeastOf, c)
-		worthMostGold = mostGold(worthMostGold, c)
-		worthMostPlat = mostPlat(worthMostPlat, c)
+		packCards = append(packCards, c)
+		pack.Cards = append(pack.Cards, draftCardFromCollection(uuid, wheelPackNum))
 		// The first time we have a blank comma at the end, but we remove that later
-		packContents[numCards] = fmt.Sprintf("'%v', %v", c.name, packContents[numCards])
+		d.PackContents[numCards] = fmt.Sprintf("'%v', %v", c.name, d.PackContents[numCards])
 		if wheelPackNum == 0 {
 			contentsInfo = fmt.Sprintf("'[%v %2d - %3dp/%3dg] %v'\n\t%v", c.rarity, c.qty, c.plat, c.gold, c.name, contentsInfo)
 		} else {
-			contentsInfo = fmt.Sprintf("'[%v %2d - %3dp/%3dg %3d%%] %v'\n\t%v", c.rarity, c.qty, c.plat, c.gold, c.wiw[wheelPackNum], c.name, contentsInfo)
+			contentsInfo = fmt.Sprintf("'[%v %2d - %3dp/%3dg %v] %v'\n\t%v", c.rarity, c.qty, c.plat, c.gold, sprintWheelChance(c, wheelPackNum), c.name, contentsInfo)
 		}
 
 		// If we have (packSize - 7) or more cards in pack, save what we've got so we've got so we
 		// can determine what others picked
 		if numCards < (packSize - 7) {
 			prevCard := fmt.Sprintf("'%v', ", c.name)
-			previousContents[numCards] = strings.Replace(previousContents[numCards], prevCard, "", 1)
-		}
-		// record the UUID for posting to our data URL
-		if uuids == "" {
-			uuids = uuid
-		} else {
-			uuids = fmt.Sprintf("%v,%v", uuids, uuid)
+			d.PreviousContents[numCards] = strings.Replace(d.PreviousContents[numCards], prevCard, "", 1)
 		}
 	}
-	//  fmt.Printf("DEBUG: uuids string is '%v'\n", uuids)
 	// Removing the leading ", "from the packContents and contentsInfo strings
-	if (len(packContents[numCards]) > 1) && (packContents[numCards][len(packContents[numCards])-2:] == ", ") {
-		packContents[numCards] = packContents[numCards][:len(packContents[numCards])-2]
+	if (len(d.PackContents[numCards]) > 1) && (d.PackContents[numCards][len(d.PackContents[numCards])-2:] == ", ") {
+		d.PackContents[numCards] = d.PackContents[numCards][:len(d.PackContents[numCards])-2]
 	}
+	d.addPack(pack)
+	d.save()
 	if contentsInfo[len(contentsInfo)-2:] == ", " {
 		contentsInfo = contentsInfo[:len(contentsInfo)-2]
 	}
 	// Print out the contents of packs and any missing cards
 	fmt.Printf("== Pack [%v] Contents:\n\t%v", numCards, contentsInfo)
 	if numCards < (packSize - 7) {
-		fmt.Printf("-- MISSING CARDS: %v\n", previousContents[numCards])
+		fmt.Printf("-- MISSING CARDS: %v\n", d.PreviousContents[numCards])
+		fmt.Print(d.sprintShardSignals())
 	}
-	mostGold := getCardInfoWithWheelInfo(worthMostGold, wheelPackNum)
-	mostPlat := getCardInfoWithWheelInfo(worthMostPlat, wheelPackNum)
-	haveLeast := getCardInfoWithWheelInfo(haveLeastOf, wheelPackNum)
-	fmt.Println("** Computed best picks from pack:")
-	fmt.Printf("\tWorth most plat: %v\n", mostPlat)
-	fmt.Printf("\tWorth most gold: %v\n", mostGold)
-	fmt.Printf("\tHave least of: %v\n", haveLeast)
+	// Rank the cards using whichever pick profile we've been told to use
+	profile := Config["pick_profile"]
+	fmt.Print(sprintPickScores(scorePack(packCards, wheelPackNum, profile), profile))
 }
 
-// Comparison functions between cards
+// Comparison functions between cards. We use these to find the best cards we passed in draft recaps.
 // We use 'mostGold' as the ultimate tie breaker (since it's less likely to be equal than the other two)
 // For 'mostGold', We use '>' and '<' to favor cards of higher rarity if/when there's a tie since they show up later
 // in the packs (as they are right now)
@@ -1192,6 +1103,7 @@ func readCollectionCache() {
 
 	// Set this so we don't spam out card count info messages
 	loadingCacheOrPriceData = true
+	beginAuditBatch("cache load", cacheFile, "")
 
 	//re1, _ := regexp.Compile(`^(.*) : (\d+) : (\d+)( : (.*))?$`)
 	re1, _ := regexp.Compile(`^(.*) : (\d+) : (\d+)$`)
@@ -1215,6 +1127,7 @@ func readCollectionCache() {
 		// fmt.Println(scanner.Text())
 	}
 	// And, now that we're done, reset this
+	endAuditBatch()
 	loadingCacheOrPriceData = false
 }
 
@@ -1224,6 +1137,8 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 	action := f["Action"]
 	var added []interface{}
 	var removed []interface{}
+	var pickCounts map[string]int
+	var overwriteBase map[string][2]int
 	var thingNature string
 	if message == "Collection" {
 		thingNature = "Card"
@@ -1237,6 +1152,13 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 	}
 	if action == "Overwrite" {
 		Debug(Config["debug_collection_update"], fmt.Sprintf("Got an Overwrite Collection message. Doing full update of card collection for %v.\n", message))
+		// This tells us exactly what we've got, so we're done waiting on any draft picks
+		if message == "Collection" {
+			pickCounts = settlePendingPicks()
+		}
+		beginAuditBatch("Overwrite", fmt.Sprintf("%v Overwrite", message), thingNature)
+		// Hang on to what we believed so we can tell whether our updates kept up
+		overwriteBase = overwriteBefore(thingNature)
 		// If this is an Overwrite message, first thing we do is reset counts on all cards
 		for k, v := range cardCollection {
 			//      zeroItem  notZeroItem
@@ -1257,6 +1179,7 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 		// Finally, set up 'added' to be what's in the 'Complete' JSON array
 		added, _ = f["Complete"].([]interface{})
 	} else if action == "Update" {
+		updatesSinceOverwrite[thingNature]++
 		// Check message to see if we're dealing with Cards (aka "Collection") or Items (aka "Inventory")
 		if message == "Collection" {
 			// Added is what's in the 'CardsAdded' JSON array
@@ -1268,8 +1191,16 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 		}
 	}
 	// Ok, let's extract the cards and update the numbers of each card.
+	addedSource, removedSource := "CardsAdded", "CardsRemoved"
+	if message == "Inventory" {
+		addedSource, removedSource = "ItemsAdded", "ItemsRemoved"
+	}
+	if action == "Overwrite" {
+		addedSource = "Overwrite"
+	}
 	for _, u := range added {
 		card := u.(map[string]interface{})
+		setAuditSource(addedSource, card)
 		uuid := getCardUUIDFromJSON(card)
 		flags := card["Flags"]
 		name := getCardNameFromUUID(uuid)
@@ -1280,6 +1211,12 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 			continue
 		}
 		Debug(Config["debug_item_updates"], fmt.Sprintf("%v {item: %v} : [%v] %v\n", count, thingNature, uuid, name))
+		// We've already counted cards we drafted, so don't count them again
+		if action == "Update" && message == "Collection" {
+			if count = reconcilePickUpdate(uuid, count); count == 0 {
+				continue
+			}
+		}
 		if c, ok := cardCollection[uuid]; ok {
 			// Card exists.
 			// Make sure the nature is correct
@@ -1319,6 +1256,7 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 			c := Card{name: name, uuid: uuid, plat: 1, gold: 1, rarity: rarity, qty: count}
 			c.setNature(thingNature)
 			cardCollection[uuid] = c
+			auditQtyChange(uuid, "qty", 0, count)
 			if flags == "ExtendedArt" {
 				c := cardCollection[uuid]
 				Debug(Config["debug_ea_counts"], fmt.Sprintf("[collectionOrInventoryEvent] Sending off EA count of %v for %v (which was %v before updating)\n", count, c.name, c.qty))
@@ -1343,9 +1281,18 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 
 	for _, u := range removed {
 		card := u.(map[string]interface{})
+		setAuditSource(removedSource, card)
 		uuid := getCardUUIDFromJSON(card)
 		decrementCardCount(uuid)
 	}
+	endAuditBatch()
+	setAuditSource("unknown", nil)
+	if overwriteBase != nil {
+		reportOverwrite(overwriteBase, thingNature)
+	}
+	if pickCounts != nil {
+		correctPickDiscrepancies(pickCounts)
+	}
 	// And, reset this (even if it wasn't set, we'll make sure it gets unset)
 	loadingCacheOrPriceData = false
 	// Schedule our collectionCacheTimer to write out the collection to the cache file
@@ -1358,32 +1305,44 @@ func collectionOrInventoryEvent(f map[string]interface{}) {
 	collectionCacheTimer = time.AfterFunc(collectionTimerPeriod, cacheCollection)
 	// fmt.Printf("Set new collectionCacheTimer '%v'\n", collectionCacheTimer)
 	// For DEBUGGING
-	time.Sleep(time.Second * 3)
+	sleep(time.Second * 3)
 	//printCollection()
 	// fmt.Printf("Done with Collection event\n")
 }
 
 // Message: {"Winners":["Uzume, Grand Concubunny"],"Losers":["Warmaster Fuzzuko"],"User":"InGameName","Message":"GameEnded"}
 func gameEndedEvent(f map[string]interface{}) {
-	elapsed := time.Since(GameStartTime)
+	gameMutex.Lock()
+	defer gameMutex.Unlock()
+	elapsed := now().Sub(GameStartTime)
 	winners := f["Winners"].([]interface{})
 	winner := winners[0].(string)
 	winner = strings.TrimSpace(winner)
 	losers := f["Losers"].([]interface{})
 	loser := losers[0].(string)      // Gotta convert this to a string
 	loser = strings.TrimSpace(loser) // Then I can use TrimSpace() on it.
+	endTurn()
 	fmt.Printf("%v triumphed over %v in an elapsed time of %vm %vs\n", winner, loser, int(elapsed.Minutes()), int(elapsed.Seconds())%60)
+	recordMatch(winner, loser)
+	saveLastBoard()
 	resetGame()
 }
 
 // Message: {"Players":[],"User":"InGameName","Message":"GameStarted"}
 func gameStartedEvent() {
-	GameStartTime = time.Now()
+	gameMutex.Lock()
+	defer gameMutex.Unlock()
+	GameStartTime = now()
 	fmt.Printf("Game started at %v\n", GameStartTime.Format(time.UnixDate))
+	gameStartSeen = true
+	healthSamples = nil
+	resetTurns()
+	resetReveals()
+	saveLastBoard()
 	resetGame()
 }
 
-// Set up game in progress
+// Set up game in progress. The caller holds gameMutex.
 func resetGame() {
 	pnums := map[string]int{"p1": 1, "p2": 2}
 	player1 := Player{name: "p1", id: 1, champion: gameCard{name: "Unknown"}}
@@ -1408,7 +1367,8 @@ func logoutEvent(s string) {
 		fmt.Printf("Thank you for visiting the world of Entrath. We look forward to seeing you again.\n")
 	}
 	// We also want to clear out the card collections since we should get a full update of all cards and inventory items
-	// in the next two messages
+	// in the next two messages. That isn't a real change, so it stays out of the audit trail.
+	saveOverwriteBaseline()
 	for k, v := range cardCollection {
 		if Config["debug_item_updates"] == "true" {
 			fmt.Printf("Doing Zero for %v (%v)\n", v.name, v.nature)
@@ -1426,16 +1386,20 @@ func playerUpdatedEvent(f map[string]interface{}) {
 	var p Player
 	var pptr *Player
 	var msg string
+	gameMutex.Lock()
+	defer gameMutex.Unlock()
 	// If this is the first time through, do a resetGame
 	if currentGame.p1.id == 0 {
 		resetGame()
 	}
 	// See if this player is config'd and figure out if it's p1 or p2
 	pptr = checkPlayerConfigured(f["Id"])
+	before := pptr.resources
 	// Go ahead and make the updates
 	// p, c = updatePlayer(pptr, res, thresholds)
 	p, msg = updatePlayer(pptr, f)
-	if msg != "" {
+	noteTurnResources(pptr, before)
+	if msg != "" && !turnSummaries() {
 		fmt.Printf("%v", msg)
 		if Config["debug_player_update"] == "true" {
 			fmt.Printf("PlayerUpdate for %v\n", p)
@@ -1444,7 +1408,7 @@ func playerUpdatedEvent(f map[string]interface{}) {
 }
 
 // See if this player ID has been allocated for current game yet. If not, add it to the next
-// empty slot in the current game
+// empty slot in the current game. The caller holds gameMutex.
 func checkPlayerConfigured(fID interface{}) *Player {
 	id := floatToInt(fID)
 	// If this is 1, the first player hasn't been initialized yet.
@@ -1568,6 +1532,11 @@ func saveDeckEvent(f map[string]interface{}) {
 	deckGValue += gv
 	// And print out the value of the deck
 	fmt.Printf("Saved Deck '%v' for Champion '%v' saved. The deck's value is %vp and %vg\n", deckName, champion, deckPValue, deckGValue)
+	r := saveDeckRevision(DeckRevision{Name: fmt.Sprintf("%v", deckName), Champion: fmt.Sprintf("%v", champion), Deck: deckCardCounts(deck), Sideboard: deckCardCounts(sideboard)})
+	if r.Revision > 1 {
+		fmt.Printf("That's revision %v of '%v'\n", r.Revision, r.Name)
+	}
+	noteSavedDeck(fmt.Sprintf("%v", deckName), fmt.Sprintf("%v", champion))
 }
 
 func ladderEvent(f map[string]interface{}) {
@@ -1577,6 +1546,7 @@ func ladderEvent(f map[string]interface{}) {
 	division := floatToInt(f["Division"].(float64))
 	divisionName := ladderDivisionLookup[division]
 	cosmicRank := f["CosmicRank"]
+	noteLadder(fmt.Sprintf("%v", ladderType))
 	if division == 4 {
 		fmt.Printf("Your %s Cosmic Rank is %v\n", ladderType, cosmicRank)
 	} else {
@@ -1603,6 +1573,7 @@ func tournamentEvent(f map[string]interface{}) {
 	players = tD["Players"].([]interface{})
 	// User (so we can target messages)
 	User := f["User"]
+	noteTournament()
 
 	if Config["tournament_debug"] == "true" {
 		fmt.Printf("= TOURNAMENT update for id %d (style %v and format %v for user %v)\n", tID, tStyle, tFormat, User)
@@ -1845,9 +1816,15 @@ func incoming(rw http.ResponseWriter, req *http.Request) {
 		fmt.Printf("Got blank body. Here are the headers:\n%v\n", req.Header)
 		return
 	}
+	handleMessage(body)
+}
+
+// Handle a single API message from Hex. This is split out from incoming() so the draft simulator can
+// feed recorded messages through exactly the same path.
+func handleMessage(body []byte) {
 	var f map[string]interface{}
 	// fmt.Printf("Contents of body:\n\t%v\n", string(body))
-	err = json.Unmarshal(body, &f)
+	err := json.Unmarshal(body, &f)
 	if err != nil {
 		fmt.Printf("ERROR: Could not unmarshal the following body:\n\t>>>%v<<< (could not unmarshall error)\n", string(body))
 		return
@@ -1948,6 +1925,54 @@ func loadDefaults() map[string]string {
 	retMap["version_url"] = "http://doc-x.net/hex/downloads/hexapi_version.txt"
 	// Here so we can copy and paste it later
 	retMap["post_draft_data_url"] = "http://doc-x.net/hex/draft_catcher.rb"
+	// Sharing draft data is opt-in. Set 'upload_draft_data' to "true" to send packs and picks to the URL above
+	retMap["upload_draft_data"] = "false"
+	retMap["upload_batch_size"] = "10"
+	retMap["upload_queue_file"] = "upload_queue.json"
+	// Local card database of UUIDs to names. 'card_db_import' can be a comma separated list of extra files to read in
+	retMap["card_db_file"] = "carddb.txt"
+	// Where (and whether) we ask about UUIDs that aren't in the card database
+	retMap["remote_name_lookup"] = "true"
+	retMap["name_lookup_url"] = "http://doc-x.net/hex/uuid_to_name.rb"
+	retMap["name_lookup_batch_size"] = "50"
+	retMap["name_lookup_queue_file"] = "name_lookup_queue.txt"
+	retMap["name_lookup_max_retries"] = "8"
+	// Settings for everything we fetch over HTTP. 'http_proxy' can be set to a proxy URL if need be
+	retMap["http_timeout"] = "30"
+	retMap["http_retries"] = "3"
+	retMap["http_retry_backoff"] = "500"
+	// Where we keep a log file for each draft and how many booster rounds make up a draft
+	retMap["draft_log_dir"] = "drafts"
+	retMap["draft_rounds"] = "3"
+	// Every draft's cost and pool value goes in here, one line of JSON each, so we can work out whether drafting pays
+	retMap["draft_ledger_file"] = "draft_ledger.jsonl"
+	// How we rank cards in draft packs. Profiles are "collector", "profit" and "competitive"
+	retMap["pick_profile"] = "collector"
+	retMap["pick_list_length"] = "5"
+	retMap["playset_size"] = "4"
+	retMap["card_ratings_file"] = "card_ratings.txt"
+	// Where wheel chances come from: "blend", "local" (our own drafts) or "downloaded". When blending,
+	// the downloaded figure counts for this many of our own observations
+	retMap["wheel_stats"] = "blend"
+	retMap["wheel_stats_weight"] = "10"
+	// How many seconds we wait for the Collection update for a card we drafted before calling it unmatched
+	retMap["pick_reconcile_timeout"] = "120"
+	// Every change to a card count goes in here along with where it came from, one line of JSON each
+	retMap["collection_audit_file"] = "collection_audit.jsonl"
+	// Print it when a card in a game gains or loses keywords like Flight or Speed
+	retMap["show_keyword_changes"] = "true"
+	// Every game we play goes in here, one line of JSON each
+	retMap["match_history_file"] = "match_history.jsonl"
+	// A game counts as ladder or tournament if we saw a Ladder or Tournament message this many minutes before it started
+	retMap["game_format_window"] = "30"
+	// Games at least this many minutes long count as long ones in the match statistics
+	retMap["long_game_minutes"] = "20"
+	// Every deck we save goes in here, one revision per line of JSON
+	retMap["deck_library_file"] = "deck_library.jsonl"
+	// During a game, print one summary line per turn instead of a line for every card that moves
+	retMap["turn_summaries"] = "true"
+	// Extra card metadata (shard, cost, stats, type, set) to fill in what the price feed doesn't have
+	retMap["card_metadata_file"] = "card_metadata.json"
 	// Here so we can copy and paste it later
 	retMap["key"] = "val"
 	return retMap
@@ -1982,29 +2007,9 @@ func readConfig(fname string, config map[string]string) map[string]string {
 }
 
 // Utility function to encapsulate sending GET HTTP requests and getting back
-// the results
-func grabFromURL(url string) (body string, err error) {
-	// Set up a flag to see if we had a problem
-	gotHTTPError := false
-	// Get the content from 'url'
-	resp, err := http.Get(url)
-	if err != nil {
-		gotHTTPError = true
-	}
-	// If we had a problem, return a blank string and the error code
-	// Othwerise, read in the contents of the URL and pass it back
-	if gotHTTPError {
-		return "", err
-	}
-	defer resp.Body.Close()
-	bodyBytes, ioErr := ioutil.ReadAll(resp.Body)
-	if ioErr != nil {
-		log.Fatal(ioErr)
-	}
-	// We like to deal with strings over byte arrays, so stringify before
-	// returning.
-	strBody := string(bodyBytes)
-	return strBody, err
+// the results. Uses the shared fetcher so we get timeouts, retries and status checks.
+func grabFromURL(ctx context.Context, url string) (body string, err error) {
+	return sharedFetcher().get(ctx, url)
 }
 
 // Retrieve card prices and AA card info to prime the collection pump
@@ -2022,7 +2027,7 @@ func getCardPriceInfo() {
 	}
 	if Config["local_price_file"] == "" {
 		fmt.Printf("Retrieving prices from %v\n", Config["price_url"])
-		body, err = grabFromURL(Config["price_url"])
+		body, err = grabFromURL(context.Background(), Config["price_url"])
 		if err != nil {
 			gotHTTPError = true
 		}
@@ -2066,6 +2071,7 @@ func getCardPriceInfo() {
 		var gold int
 		var dpc = make(map[string]interface{})
 		var nature string
+		cardDBChanged := false
 
 		// Reduce the spamminess of loading collection info
 		if Config["debug_price_updates"] != "true" {
@@ -2088,6 +2094,9 @@ func getCardPriceInfo() {
 				rarity = "?"
 			}
 			uuid = c["uuid"].(string)
+			if recordCardName(uuid, name) {
+				cardDBChanged = true
+			}
 			p = c["PLATINUM"].(map[string]interface{})
 			plat = int(p["avg"].(float64))
 			g = c["GOLD"].(map[string]interface{})
@@ -2104,9 +2113,13 @@ func getCardPriceInfo() {
 				c := cardCollection[uuid]
 				c.name = name
 				c.uuid = uuid
-				c.nature = nature
+				// Don't throw away a nature we know about just because the feed doesn't say
+				if nature != "Unknown" || c.nature == "" {
+					c.nature = nature
+				}
 				c.plat = plat
 				c.gold = gold
+				c.platGuessed, c.goldGuessed = false, false
 				c.rarity = rarity
 				if dpc["9"] != nil {
 					c.wiw[9] = floatToInt(dpc["9"].(float64))
@@ -2170,6 +2183,7 @@ func getCardPriceInfo() {
 				// And update our name to uuid map
 				ntum[name] = uuid
 			}
+			applyPriceFeedMetadata(uuid, c)
 			nc := cardCollection[uuid]
 			Debug(Config["debug_price_updates"], fmt.Sprintf("Added  %v [%v] {%v} %vp - %vg", nc.name, nc.rarity, nc.nature, nc.plat, nc.gold))
 
@@ -2177,6 +2191,15 @@ func getCardPriceInfo() {
 
 		// Now, turn back on info messages for changes in card counts
 		loadingCacheOrPriceData = false
+		// And save any names we didn't already know about
+		if cardDBChanged {
+			writeCardDB()
+		}
+	}
+
+	// Decks are worth something different now
+	if updatingData && !gotHTTPError {
+		revalueDecks()
 	}
 
 	// Set our refresh timer to come back and do this again later
@@ -2198,10 +2221,12 @@ func getCardPriceInfo() {
 				c := cardCollection[k]
 				c.plat = 1
 				c.gold = 1
+				c.goldGuessed = true
 				cardCollection[k] = c
 			}
 			if v.plat == 0 {
 				c := cardCollection[k]
+				c.platGuessed = true
 				c.plat = int(c.gold / goldPlatRatio)
 				// In cases where this is actually zero after the comparison, go ahead and make it a minimum of 1
 				if c.plat == 0 {
@@ -2212,6 +2237,7 @@ func getCardPriceInfo() {
 			}
 			if v.gold == 0 {
 				c := cardCollection[k]
+				c.goldGuessed = true
 				c.gold = c.plat * goldPlatRatio
 				cardCollection[k] = c
 				continue
@@ -2219,6 +2245,8 @@ func getCardPriceInfo() {
 		}
 
 	}
+	// Fill in anything the price feed didn't tell us from our local metadata file
+	applyCardMetadataFile()
 	// And now let them know we're ready
 	fmt.Println("Price data processed")
 }
@@ -2260,13 +2288,32 @@ func main() {
 			fmt.Printf("   %v = %v\n", k, v)
 		}
 	}
+	// If we were handed a command, run that instead of listening for events
+	if len(os.Args) > 1 {
+		readCardDB()
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	//  fmt.Printf("Using the following configuration values\n\tPrice URL (price_url): '%v'\n\tCollection file (collection_file): '%v'\n\tAlternate Art/Promo List URL(aa_promo_url): '%v'\n", Config["price_url"], Config["collection_file"], Config["aa_promo_url"])
 	// Check to see if we're running the most recent version
 	checkProgramVersion()
+	// Read in the card database so we know names for UUIDs the price feed doesn't cover
+	readCardDB()
+	// And pick up any name lookups we didn't finish last time
+	readNameLookupQueue()
 	// Retrieve card price info
 	getCardPriceInfo()
-	// Read in our collection cache
+	// Read in our collection cache, checking it against where the audit trail left off
+	readAuditLog()
 	readCollectionCache()
+	readDeckLibrary()
+	// Pick up any draft we were in the middle of
+	restoreDraft()
+	// Work out how often cards come back around in our own drafts
+	learnWheelStats(readDraftLogs())
+	// And read in our own card ratings for pick scoring
+	readCardRatings()
+	// Send any draft data that didn't make it out last time
+	readUploadQueue()
 	// Run this to truncate API log file if we are logging
 	truncateAPILogFile()
 	fmt.Println("Beginning to listen for API events")
@@ -2278,6 +2325,22 @@ func main() {
 	http.HandleFunc("/accepts.txt", acceptsRequest)
 	http.HandleFunc("/refresh", refreshRequest)
 	http.HandleFunc("/filedump", fileDumpRequest)
+	http.HandleFunc("/unresolved", unresolvedNamesRequest)
+	http.HandleFunc("/ledger", ledgerRequest)
+	http.HandleFunc("/reconcile", reconcileRequest)
+	http.HandleFunc("/history", historyRequest)
+	http.HandleFunc("/discrepancies", discrepanciesRequest)
+	http.HandleFunc("/board", boardRequest)
+	http.HandleFunc("/games", matchesRequest)
+	http.HandleFunc("/stats", statsRequest)
+	http.HandleFunc("/decks", decksRequest)
+	http.HandleFunc("/complete", completionRequest)
+	http.HandleFunc("/import", importRequest)
+	http.HandleFunc("/export", exportRequest)
+	http.HandleFunc("/turns", turnsRequest)
+	http.HandleFunc("/reveals", revealsRequest)
+	http.HandleFunc("/meta", metaRequest)
+	http.HandleFunc("/search", searchRequest)
 	// Now that we've registered what we want, start it up
 	log.Fatal(http.ListenAndServe(":5000", nil))
 }
@@ -0,0 +1,49 @@
+// JSON lines files: one JSON value per line, added to as things happen and read back a line at a time
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Add values to the end of a JSON lines file, one line each. Values that won't encode are skipped.
+func appendJSONLines(file string, values ...interface{}) {
+	var lines []byte
+	for _, v := range values {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			fmt.Printf("Could not encode %+v for %v: %v\n", v, file, err)
+			continue
+		}
+		lines = append(lines, append(blob, '\n')...)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		fmt.Printf("Could not append to file %v for writing: %v\n", file, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(lines); err != nil {
+		fmt.Printf("Could not write to file %v: %v\n", file, err)
+	}
+}
+
+// Hand each line of a JSON lines file to 'each' to decode. A file that isn't there reads as empty.
+func readJSONLines(file string, each func(line []byte)) {
+	in, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		each(scanner.Bytes())
+	}
+}
@@ -0,0 +1,40 @@
+// Test cases for reading and writing JSON lines files
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONLines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lines.json")
+	readJSONLines(file, func(line []byte) {
+		t.Errorf("read %q from a file that isn't there", line)
+	})
+
+	type entry struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	appendJSONLines(file, entry{"a", 1}, entry{"b", 2})
+	// Something that isn't one of ours, then a value that won't encode, which is skipped
+	f, _ := os.OpenFile(file, os.O_WRONLY|os.O_APPEND, 0660)
+	f.Write([]byte("not json\n"))
+	f.Close()
+	appendJSONLines(file, entry{"c", 3}, make(chan int))
+
+	var got []entry
+	readJSONLines(file, func(line []byte) {
+		var e entry
+		if err := json.Unmarshal(line, &e); err == nil {
+			got = append(got, e)
+		}
+	})
+	if want := []entry{{"a", 1}, {"b", 2}, {"c", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %v but we expected %v", got, want)
+	}
+}
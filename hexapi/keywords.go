@@ -0,0 +1,69 @@
+// Card keywords: turn the Attributes bitmask from CardUpdated into names and notice when they change
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keyword names for each bit of Attributes, from the table at the top of hexapi.go
+var cardAttributes = []string{
+	"Lifedrain",
+	"Flight",
+	"Speed",
+	"Skyguard",
+	"Crush",
+	"Steadfast",
+	"Invincible",
+	"Spellshield",
+	"Unique",
+	"Can't Attack",
+	"Can't Block",
+	"Defensive",
+	"Must Attack",
+	"Does not auto-ready",
+	"Swiftstrike",
+	"Rage",
+	"Must Block",
+	"Unblockable",
+	"Prevent Combat Damage",
+	"Prevent Non-Combat Damage",
+	"Doublestrike",
+	"Cannot Inflict Combat Damage",
+	"Cannot Inflict Non-Combat Damage",
+	"Enters Play Exhausted",
+	"Inspire",
+	"Escalation",
+	"Does not ready next ready step",
+	"Lethal",
+	"Quick",
+	"Blessing of the Fallen",
+	"Must be blocked",
+}
+
+// The keywords set in an Attributes value, lowest bit first
+func attributeKeywords(attrs int) []string {
+	return cardAttributeFlags.decode(attrs)
+}
+
+// Which keywords were gained and lost going from one Attributes value to another
+func keywordChanges(before int, after int) (gained []string, lost []string) {
+	return attributeKeywords(after &^ before), attributeKeywords(before &^ after)
+}
+
+// Something like "Yeti Spy gained Flight, Speed and lost Rage", or "" if nothing changed
+func sprintKeywordChanges(name string, before int, after int) string {
+	gained, lost := keywordChanges(before, after)
+	var changes []string
+	if len(gained) > 0 {
+		changes = append(changes, "gained "+strings.Join(gained, ", "))
+	}
+	if len(lost) > 0 {
+		changes = append(changes, "lost "+strings.Join(lost, ", "))
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v %v", name, strings.Join(changes, " and "))
+}
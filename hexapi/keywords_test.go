@@ -0,0 +1,50 @@
+// Test cases for card keywords
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttributeKeywords(t *testing.T) {
+	for _, f := range []struct {
+		attrs int
+		want  []string
+	}{
+		{0, nil},
+		{1, []string{"Lifedrain"}},
+		{2 + 4, []string{"Flight", "Speed"}},
+		{16384 + 32768, []string{"Swiftstrike", "Rage"}},
+		{1 << 24, []string{"Inspire"}},
+		{1 << 30, []string{"Must be blocked"}},
+	} {
+		got := attributeKeywords(f.attrs)
+		if !reflect.DeepEqual(got, f.want) {
+			t.Errorf("attributeKeywords(%v) == %v but we expected %v", f.attrs, got, f.want)
+		}
+	}
+}
+
+func TestKeywordChanges(t *testing.T) {
+	got := sprintKeywordChanges("Yeti", 4|32768, 2|4)
+	want := "Yeti gained Flight and lost Rage"
+	if got != want {
+		t.Errorf("sprintKeywordChanges() == %q but we expected %q", got, want)
+	}
+	if got := sprintKeywordChanges("Yeti", 6, 6); got != "" {
+		t.Errorf("sprintKeywordChanges() with no changes == %q but we expected nothing", got)
+	}
+
+	// A troop in play gaining Flight should show up on the board
+	Config = map[string]string{"show_keyword_changes": "true"}
+	resetGame()
+	yeti := testCardUpdatedMessage(10, "Yeti", 1, 8, 2, 1)
+	cardUpdatedEvent(yeti)
+	yeti["Attributes"] = float64(2)
+	cardUpdatedEvent(yeti)
+	if board := sprintBoard(); !strings.Contains(board, "Yeti [2/1] {Flight}") {
+		t.Errorf("sprintBoard() gave us:\n%v\nbut we expected the Yeti to have Flight", board)
+	}
+}
@@ -0,0 +1,162 @@
+// Lifetime draft economics: what each draft cost and what the cards we took are worth
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// LedgerEntry is what one draft cost us and what we got out of it. The pool is kept as
+// UUIDs so we can value it again at whatever prices are current.
+type LedgerEntry struct {
+	DraftID  string   `json:"draft_id"`
+	Format   string   `json:"format"`
+	Date     string   `json:"date"`
+	CostPlat int      `json:"cost_plat"`
+	CostGold int      `json:"cost_gold"`
+	PoolPlat int      `json:"pool_plat"`
+	PoolGold int      `json:"pool_gold"`
+	Pool     []string `json:"pool"`
+}
+
+// ledgerTotals adds up a group of ledger entries
+type ledgerTotals struct {
+	drafts      int
+	costPlat    int
+	costGold    int
+	pickedPlat  int
+	pickedGold  int
+	currentPlat int
+	currentGold int
+}
+
+// Add a finished draft to the ledger file. Each entry is a line of JSON.
+func recordDraftInLedger(d *Draft) {
+	ledgerFile := Config["draft_ledger_file"]
+	if ledgerFile == "" || len(d.Picks) == 0 {
+		return
+	}
+	e := LedgerEntry{
+		DraftID:  d.ID,
+		Format:   d.Format,
+		Date:     d.Started.Format("2006-01-02"),
+		CostPlat: d.PackCost * d.Rounds,
+		CostGold: d.PackGoldCost * d.Rounds,
+	}
+	e.PoolPlat, e.PoolGold = d.poolValue()
+	for _, p := range d.Picks {
+		e.Pool = append(e.Pool, p.Card.UUID)
+	}
+	appendJSONLines(ledgerFile, e)
+}
+
+// Read the ledger. If a draft shows up more than once, the last entry for it wins.
+func readLedger() []LedgerEntry {
+	var entries []LedgerEntry
+	seen := make(map[string]int)
+	readJSONLines(Config["draft_ledger_file"], func(line []byte) {
+		var e LedgerEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return
+		}
+		if i, ok := seen[e.DraftID]; ok {
+			entries[i] = e
+			return
+		}
+		seen[e.DraftID] = len(entries)
+		entries = append(entries, e)
+	})
+	return entries
+}
+
+// What the pool in a ledger entry is worth at today's prices
+func (e LedgerEntry) currentValue() (plat, gold int) {
+	for _, uuid := range e.Pool {
+		c := cardCollection[uuid]
+		plat += c.plat
+		gold += c.gold
+	}
+	return
+}
+
+func (t *ledgerTotals) add(e LedgerEntry) {
+	t.drafts++
+	t.costPlat += e.CostPlat
+	t.costGold += e.CostGold
+	t.pickedPlat += e.PoolPlat
+	t.pickedGold += e.PoolGold
+	plat, gold := e.currentValue()
+	t.currentPlat += plat
+	t.currentGold += gold
+}
+
+// Return on investment as a percentage
+func roi(value int, cost int) float64 {
+	if cost == 0 {
+		return 0
+	}
+	return float64(value-cost) * 100 / float64(cost)
+}
+
+func (t ledgerTotals) String() string {
+	return fmt.Sprintf("%3d drafts cost %6dp (%8dg) | at pick time %6dp (%8dg) ROI %6.1f%% (%6.1f%%) | now %6dp (%8dg) ROI %6.1f%% (%6.1f%%)",
+		t.drafts, t.costPlat, t.costGold,
+		t.pickedPlat, t.pickedGold, roi(t.pickedPlat, t.costPlat), roi(t.pickedGold, t.costGold),
+		t.currentPlat, t.currentGold, roi(t.currentPlat, t.costPlat), roi(t.currentGold, t.costGold))
+}
+
+// Totals for the ledger grouped by whatever 'key' pulls out of each entry, sorted by group name
+func groupLedger(entries []LedgerEntry, key func(LedgerEntry) string) ([]string, map[string]*ledgerTotals) {
+	groups := make(map[string]*ledgerTotals)
+	var names []string
+	for _, e := range entries {
+		k := key(e)
+		if _, ok := groups[k]; !ok {
+			groups[k] = &ledgerTotals{}
+			names = append(names, k)
+		}
+		groups[k].add(e)
+	}
+	sort.Strings(names)
+	return names, groups
+}
+
+// Lifetime, monthly and per format ROI reports
+func ledgerReport() string {
+	entries := readLedger()
+	if len(entries) == 0 {
+		return fmt.Sprintf("No drafts recorded in '%v' yet\n", Config["draft_ledger_file"])
+	}
+	var lifetime ledgerTotals
+	for _, e := range entries {
+		lifetime.add(e)
+	}
+	s := "==========================      DRAFT ECONOMICS LEDGER      ==========================\n"
+	s += fmt.Sprintf("Lifetime: %v\n", lifetime)
+	s += "By month:\n"
+	months, byMonth := groupLedger(entries, func(e LedgerEntry) string {
+		if len(e.Date) < 7 {
+			return "unknown"
+		}
+		return e.Date[:7]
+	})
+	for _, m := range months {
+		s += fmt.Sprintf("\t%-10v %v\n", m, byMonth[m])
+	}
+	s += "By format:\n"
+	formats, byFormat := groupLedger(entries, func(e LedgerEntry) string { return e.Format })
+	for _, f := range formats {
+		s += fmt.Sprintf("\t%-10v %v\n", f, byFormat[f])
+	}
+	return s
+}
+
+func ledgerRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print draft ledger received.")
+	report := ledgerReport()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
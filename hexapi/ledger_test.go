@@ -0,0 +1,70 @@
+// Test cases for the draft economics ledger
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLedger(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	Config["draft_ledger_file"] = filepath.Join(t.TempDir(), "ledger.jsonl")
+	for _, c := range []struct{ id, format string }{
+		{"first", "Draft"},
+		{"second", "Draft"},
+		{"second", "Sealed"},
+	} {
+		d := newDraft(c.format)
+		d.ID = c.id
+		d.PackCost = 10
+		d.PackGoldCost = 1000
+		d.Rounds = 1
+		d.PackNum = 17
+		d.addPick(draftCardFromCollection(uuids[9], 0))
+		d.finish()
+	}
+	entries := readLedger()
+	// The second draft was recorded twice, so the later entry should win
+	if len(entries) != 2 {
+		t.Fatalf("read %v ledger entries but we expected 2", len(entries))
+	}
+	if entries[1].Format != "Sealed" {
+		t.Errorf("second ledger entry has format %v but we expected the later Sealed entry", entries[1].Format)
+	}
+	e := entries[0]
+	if e.CostPlat != 10 || e.PoolPlat != 10 || e.PoolGold != 1000 {
+		t.Errorf("ledger entry is %+v but we expected a cost of 10p and a pool of 10p/1000g", e)
+	}
+	// Prices go up after the draft, so the pool is worth more now than when we picked it
+	c := cardCollection[uuids[9]]
+	c.plat = 20
+	cardCollection[uuids[9]] = c
+	if plat, _ := e.currentValue(); plat != 20 {
+		t.Errorf("current value of ledger entry is %vp but we expected 20p", plat)
+	}
+	report := ledgerReport()
+	for _, want := range []string{"Lifetime:", "By month:", "By format:", "Sealed", "ROI  100.0%"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("ledger report does not contain %q:\n%v", want, report)
+		}
+	}
+}
+
+func TestROI(t *testing.T) {
+	for _, c := range []struct {
+		value, cost int
+		want        float64
+	}{
+		{10, 10, 0},
+		{20, 10, 100},
+		{5, 10, -50},
+		{5, 0, 0},
+	} {
+		got := roi(c.value, c.cost)
+		if got != c.want {
+			t.Errorf("roi(%v, %v) == %v but we expected %v", c.value, c.cost, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Match history: every game we play, who was in it, who won and how the health totals went
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HealthSample is a champion's health at some point in a game, in seconds from the start
+type HealthSample struct {
+	Seconds  int    `json:"seconds"`
+	Champion string `json:"champion"`
+	Health   int    `json:"health"`
+}
+
+// MatchRecord is one finished game. Result is "won", "lost" or "unknown" (when the deck we last
+// saved wasn't for either champion, we can't tell which side was ours).
+type MatchRecord struct {
+	ID           string         `json:"id"`
+	Started      time.Time      `json:"started"`
+	Ended        time.Time      `json:"ended"`
+	Seconds      int            `json:"seconds"`
+	Format       string         `json:"format"`
+	LadderType   string         `json:"ladder_type,omitempty"`
+	Deck         string         `json:"deck,omitempty"`
+	DeckChampion string         `json:"deck_champion,omitempty"`
+	Champions    []string       `json:"champions"`
+	Winner       string         `json:"winner"`
+	Loser        string         `json:"loser"`
+	Result       string         `json:"result"`
+	Turns        int            `json:"turns,omitempty"`
+	Health       []HealthSample `json:"health,omitempty"`
+	// Cards the opponent showed us, and how many of each
+	OpponentReveals map[string]int `json:"opponent_reveals,omitempty"`
+	// We didn't see the game start (we were started part way through it), so we don't know how long
+	// it went. Started is when it ended.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// The deck we saved most recently. Hex doesn't tell us which deck a game is played with, so this
+// is our best guess.
+var savedDeckName string
+var savedDeckChampion string
+
+// Health totals for the game in progress
+var healthSamples []HealthSample
+
+// Whether we saw the game in progress start. If we didn't, GameStartTime is from some other game
+// (or when we started up).
+var gameStartSeen bool
+
+// Hex doesn't say what kind of game we're in either. Ladder and Tournament messages show up around
+// ladder and tournament games, so we remember when we last saw each.
+var lastLadderSeen time.Time
+var lastLadderType string
+var lastTournamentSeen time.Time
+
+func noteSavedDeck(name string, champion string) {
+	savedDeckName = name
+	savedDeckChampion = champion
+}
+
+func noteLadder(ladderType string) {
+	lastLadderSeen = now()
+	lastLadderType = ladderType
+}
+
+func noteTournament() {
+	lastTournamentSeen = now()
+}
+
+// Record a champion's health, unless it's what we had for them last time
+func recordHealth(champion string, health int) {
+	for i := len(healthSamples) - 1; i >= 0; i-- {
+		if healthSamples[i].Champion == champion {
+			if healthSamples[i].Health == health {
+				return
+			}
+			break
+		}
+	}
+	healthSamples = append(healthSamples, HealthSample{Seconds: int(now().Sub(GameStartTime).Seconds()), Champion: champion, Health: health})
+}
+
+// Work out the format of a game that just ended: a tournament or ladder if we heard about one
+// within game_format_window minutes of the game, casual otherwise.
+func gameFormat(started time.Time) (string, string) {
+	window, err := strconv.Atoi(Config["game_format_window"])
+	if err != nil {
+		window = 30
+	}
+	since := started.Add(-time.Duration(window) * time.Minute)
+	if lastTournamentSeen.After(since) {
+		return "tournament", ""
+	}
+	if lastLadderSeen.After(since) {
+		return "ladder", lastLadderType
+	}
+	return "casual", ""
+}
+
+// Build the record for a game that just ended
+func newMatchRecord(winner string, loser string) MatchRecord {
+	ended := now()
+	started := GameStartTime
+	if !gameStartSeen {
+		started = ended
+	}
+	m := MatchRecord{
+		ID:              started.Format("20060102-150405"),
+		Started:         started,
+		Ended:           ended,
+		Seconds:         int(ended.Sub(started).Seconds()),
+		Deck:            savedDeckName,
+		DeckChampion:    savedDeckChampion,
+		Winner:          winner,
+		Loser:           loser,
+		Result:          "unknown",
+		Health:          healthSamples,
+		Turns:           len(turns),
+		OpponentReveals: opponentReveals(),
+		Partial:         !gameStartSeen,
+	}
+	// The health samples are timed from a start we didn't see
+	if m.Partial {
+		m.Health = nil
+	}
+	m.Format, m.LadderType = gameFormat(started)
+	for _, p := range []Player{currentGame.p1, currentGame.p2} {
+		if p.champion.name != "Unknown" && p.champion.name != "" {
+			m.Champions = append(m.Champions, p.champion.name)
+		}
+	}
+	if len(m.Champions) < 2 {
+		m.Champions = []string{winner, loser}
+	}
+	// In a mirror match our champion both won and lost, so we can't tell which side was ours
+	switch {
+	case savedDeckChampion == "" || winner == loser:
+	case savedDeckChampion == winner:
+		m.Result = "won"
+	case savedDeckChampion == loser:
+		m.Result = "lost"
+	}
+	return m
+}
+
+// Our champion and theirs, if we know which was which
+func (m MatchRecord) sides() (string, string) {
+	switch m.Result {
+	case "won":
+		return m.Winner, m.Loser
+	case "lost":
+		return m.Loser, m.Winner
+	}
+	return "", ""
+}
+
+// Save a game that just ended to the match history file. Each record is a line of JSON.
+func recordMatch(winner string, loser string) {
+	m := newMatchRecord(winner, loser)
+	healthSamples = nil
+	gameStartSeen = false
+	historyFile := Config["match_history_file"]
+	if historyFile == "" {
+		return
+	}
+	appendJSONLines(historyFile, m)
+}
+
+// Read the match history, oldest game first
+func readMatches() []MatchRecord {
+	var matches []MatchRecord
+	readJSONLines(Config["match_history_file"], func(line []byte) {
+		var m MatchRecord
+		if err := json.Unmarshal(line, &m); err == nil {
+			matches = append(matches, m)
+		}
+	})
+	return matches
+}
+
+// The filters games, stats and meta take
+var matchFilterKeys = []string{"id", "champion", "opponent", "deck", "format", "result", "days"}
+
+// Make sure we know every filter and 'days' is a number of days
+func checkMatchFilters(filters map[string]string) error {
+	for k, v := range filters {
+		known := false
+		for _, key := range matchFilterKeys {
+			known = known || k == key
+		}
+		if !known {
+			return fmt.Errorf("'%v' isn't something we can filter games on. Try %v", k, strings.Join(matchFilterKeys, ", "))
+		}
+		if k != "days" {
+			continue
+		}
+		if days, err := strconv.Atoi(v); err != nil || days < 0 {
+			return fmt.Errorf("days should be a number of days, not '%v'", v)
+		}
+	}
+	return nil
+}
+
+// Check a game against filters like the ones search takes: id, champion (either side), opponent,
+// deck, format, result and days (games started in the last N days). Everything but id, result and
+// days matches on part of the name.
+func matchMatches(m MatchRecord, filters map[string]string) bool {
+	contains := func(s string, sub string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(sub))
+	}
+	for k, v := range filters {
+		switch k {
+		case "id":
+			if m.ID != v {
+				return false
+			}
+		case "champion":
+			if !contains(strings.Join(m.Champions, "\n"), v) {
+				return false
+			}
+		case "opponent":
+			if _, theirs := m.sides(); theirs == "" || !contains(theirs, v) {
+				return false
+			}
+		case "deck":
+			if !contains(m.Deck, v) {
+				return false
+			}
+		case "format":
+			if !contains(m.Format+" "+m.LadderType, v) {
+				return false
+			}
+		case "result":
+			if !strings.EqualFold(m.Result, v) {
+				return false
+			}
+		case "days":
+			days, _ := strconv.Atoi(v)
+			if m.Started.Before(now().AddDate(0, 0, -days)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sprintDuration(seconds int) string {
+	return fmt.Sprintf("%vm %02ds", seconds/60, seconds%60)
+}
+
+// One line for a game
+func (m MatchRecord) String() string {
+	format := m.Format
+	if m.LadderType != "" {
+		format += " " + m.LadderType
+	}
+	deck := m.Deck
+	if deck == "" {
+		deck = "no deck saved"
+	}
+	length := sprintDuration(m.Seconds)
+	if m.Partial {
+		length = "an unknown time"
+	}
+	if m.Turns > 0 {
+		length += fmt.Sprintf(" (%v turns)", m.Turns)
+	}
+	return fmt.Sprintf("%v %v %-7v %-10v %v beat %v in %v [%v]", m.ID, m.Started.Format("2006-01-02 15:04"), m.Result, format, m.Winner, m.Loser, length, deck)
+}
+
+// How each champion's health went over the game
+func (m MatchRecord) sprintHealth() string {
+	s := ""
+	for _, h := range m.Health {
+		s += fmt.Sprintf("\t%7v %v: %v\n", sprintDuration(h.Seconds), h.Champion, h.Health)
+	}
+	return s
+}
+
+func filterMatches(matches []MatchRecord, filters map[string]string) ([]MatchRecord, error) {
+	if err := checkMatchFilters(filters); err != nil {
+		return nil, err
+	}
+	var found []MatchRecord
+	for _, m := range matches {
+		if matchMatches(m, filters) {
+			found = append(found, m)
+		}
+	}
+	return found, nil
+}
+
+// The games that match the filters. If there's only one we show its health totals as well.
+func matchHistoryReport(filters map[string]string) string {
+	found, err := filterMatches(readMatches(), filters)
+	if err != nil {
+		return fmt.Sprintf("%v\n", err)
+	}
+	s := ""
+	for _, m := range found {
+		s += m.String() + "\n"
+	}
+	if len(found) == 1 {
+		s += found[0].sprintHealth()
+		if len(found[0].OpponentReveals) > 0 {
+			s += fmt.Sprintf("\tOpponent revealed: %v\n", sprintRevealCounts(found[0].OpponentReveals))
+		}
+	}
+	return s + fmt.Sprintf("%v games found\n", len(found))
+}
+
+func matchesRequest(rw http.ResponseWriter, req *http.Request) {
+	filters := queryFilters(req)
+	fmt.Printf("Request for match history matching %v received.\n", filters)
+	report := matchHistoryReport(filters)
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
@@ -0,0 +1,156 @@
+// Test cases for match history
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Play a whole game through the event handlers, 'seconds' long, with the given champion saved as our deck
+func testPlayGame(clock *time.Time, deckChampion string, winner string, loser string, seconds int) {
+	saveDeckEvent(map[string]interface{}{"Name": deckChampion + " Aggro", "Champion": deckChampion})
+	gameStartedEvent()
+	cardUpdatedEvent(testCardUpdatedMessage(1, winner, 1, 4, 0, 0))
+	cardUpdatedEvent(testCardUpdatedMessage(2, loser, 2, 4, 0, 0))
+	*clock = clock.Add(time.Duration(seconds/2) * time.Second)
+	cardUpdatedEvent(testCardUpdatedMessage(2, loser, 2, 4, 0, 12))
+	*clock = clock.Add(time.Duration(seconds-seconds/2) * time.Second)
+	cardUpdatedEvent(testCardUpdatedMessage(2, loser, 2, 4, 0, 0))
+	gameEndedEvent(map[string]interface{}{"Winners": []interface{}{winner}, "Losers": []interface{}{loser}})
+}
+
+func TestMatchHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hexapi-matches")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	Config = make(map[string]string)
+	Config["match_history_file"] = dir + "/match_history.jsonl"
+	Config["game_format_window"] = "30"
+	clock := time.Date(2017, time.January, 1, 12, 0, 0, 0, time.UTC)
+	savedNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = savedNow }()
+	noteSavedDeck("", "")
+	lastLadderSeen, lastTournamentSeen = time.Time{}, time.Time{}
+
+	testPlayGame(&clock, "Uzume, Grand Concubunny", "Uzume, Grand Concubunny", "Warmaster Fuzzuko", 125)
+	clock = clock.Add(time.Hour)
+	ladderEvent(map[string]interface{}{"Type": "Constructed", "Tier": float64(2), "Division": float64(1), "CosmicRank": float64(0)})
+	testPlayGame(&clock, "Zared, Mechanist", "Warmaster Fuzzuko", "Zared, Mechanist", 600)
+	clock = clock.Add(time.Hour)
+	testPlayGame(&clock, "Someone Else", "Warmaster Fuzzuko", "Uzume, Grand Concubunny", 60)
+
+	matches := readMatches()
+	if len(matches) != 3 {
+		t.Fatalf("readMatches() found %v games but we expected 3", len(matches))
+	}
+	for i, f := range []struct {
+		result  string
+		format  string
+		seconds int
+		deck    string
+	}{
+		{"won", "casual", 125, "Uzume, Grand Concubunny Aggro"},
+		{"lost", "ladder", 600, "Zared, Mechanist Aggro"},
+		{"unknown", "casual", 60, "Someone Else Aggro"},
+	} {
+		m := matches[i]
+		if m.Result != f.result || m.Format != f.format || m.Seconds != f.seconds || m.Deck != f.deck {
+			t.Errorf("game %v == %v/%v/%v/%v but we expected %v/%v/%v/%v", i, m.Result, m.Format, m.Seconds, m.Deck, f.result, f.format, f.seconds, f.deck)
+		}
+	}
+	if matches[1].LadderType != "Constructed" {
+		t.Errorf("ladder type for the second game is '%v' but we expected 'Constructed'", matches[1].LadderType)
+	}
+	// Starting health, the drop half way through and the kill
+	health := matches[0].Health
+	if len(health) != 4 || health[2].Seconds != 62 || health[2].Health != 12 || health[3].Health != 0 {
+		t.Errorf("health for the first game is %v but we expected 4 samples ending in 12 at 62s and 0", health)
+	}
+
+	for _, f := range []struct {
+		filters map[string]string
+		want    int
+	}{
+		{map[string]string{}, 3},
+		{map[string]string{"result": "won"}, 1},
+		{map[string]string{"opponent": "fuzzuko"}, 2},
+		{map[string]string{"champion": "uzume"}, 2},
+		{map[string]string{"format": "ladder"}, 1},
+		{map[string]string{"deck": "zared"}, 1},
+		{map[string]string{"id": matches[2].ID}, 1},
+	} {
+		report := matchHistoryReport(f.filters)
+		if !strings.HasSuffix(report, fmt.Sprintf("%v games found\n", f.want)) {
+			t.Errorf("matchHistoryReport(%v) == %v but we expected %v games", f.filters, report, f.want)
+		}
+	}
+
+	// Filters we don't know and days that aren't days are errors rather than matching everything
+	for _, f := range []map[string]string{{"oponent": "fuzzuko"}, {"days": "a week"}, {"days": "-1"}} {
+		if _, err := filterMatches(matches, f); err == nil {
+			t.Errorf("filterMatches(%v) didn't return an error", f)
+		}
+		if report := matchHistoryReport(f); strings.Contains(report, "games found") {
+			t.Errorf("matchHistoryReport(%v) == %v but we expected an error", f, report)
+		}
+	}
+}
+
+func TestMatchWithoutStart(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = t.TempDir() + "/match_history.jsonl"
+	clock := time.Date(2017, time.January, 1, 12, 0, 0, 0, time.UTC)
+	savedNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = savedNow }()
+	noteSavedDeck("Uzume Aggro", "Uzume")
+	gameStartSeen = false
+	GameStartTime = clock.Add(-time.Hour)
+
+	// We were started part way through this one, so all we see is the end
+	resetGame()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 5))
+	gameEndedEvent(map[string]interface{}{"Winners": []interface{}{"Uzume"}, "Losers": []interface{}{"Fuzzuko"}})
+	clock = clock.Add(time.Hour)
+	testPlayGame(&clock, "Uzume", "Uzume", "Fuzzuko", 300)
+
+	matches := readMatches()
+	if len(matches) != 2 {
+		t.Fatalf("readMatches() found %v games but we expected 2", len(matches))
+	}
+	if m := matches[0]; !m.Partial || m.Seconds != 0 || !m.Started.Equal(m.Ended) || m.Health != nil || m.Result != "won" {
+		t.Errorf("game without a start is %+v but we expected a partial win with no length", m)
+	}
+	if matches[1].Partial {
+		t.Errorf("second game is marked partial but we saw it start")
+	}
+	if report := matchStatsReport(map[string]string{}); !strings.Contains(report, "Overall:   2 games   2-0   win rate 100.0% | average   5m 00s") {
+		t.Errorf("the game without a start should count towards the win rate but not the average length:\n%v", report)
+	}
+}
+
+func TestMirrorMatch(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = t.TempDir() + "/match_history.jsonl"
+	clock := time.Date(2017, time.January, 1, 12, 0, 0, 0, time.UTC)
+	savedNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = savedNow }()
+	testPlayGame(&clock, "Uzume", "Uzume", "Uzume", 300)
+
+	matches := readMatches()
+	if len(matches) != 1 || matches[0].Result != "unknown" {
+		t.Fatalf("mirror match was recorded as %+v but we expected an unknown result", matches)
+	}
+	if report := matchStatsReport(map[string]string{}); !strings.Contains(report, "Overall:   1 games   0-0  ") {
+		t.Errorf("a mirror match shouldn't count towards the win rate:\n%v", report)
+	}
+}
@@ -0,0 +1,298 @@
+// Queue of UUIDs we need to ask the remote name lookup service about
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A UUID we're waiting on a name for, how many times we've asked and when we should ask next
+type nameLookup struct {
+	uuid     string
+	attempts int
+	next     time.Time
+}
+
+// The queue is keyed by UUID so a card only ever gets queued once. Entries that have used up
+// 'name_lookup_max_retries' stay in here (and in the queue file) so we can report on them.
+var nameLookupQueue = make(map[string]nameLookup)
+
+// The queue gets changed from event handlers and from the lookup timer, so anything touching it
+// (or the timer) holds this. Functions that don't take it themselves say so.
+var nameLookupMutex sync.Mutex
+
+// Don't back off for longer than this between attempts
+var nameLookupMaxBackoff = time.Hour * time.Duration(24)
+
+// Lines in the queue file look like 'uuid : attempts : unix time of next attempt'
+var nameLookupLineRegexp = regexp.MustCompile(`^(\S+) : (\d+) : (\d+)$`)
+
+// Read in the queue we saved last time and get the lookup timer going again
+func readNameLookupQueue() {
+	in, err := os.Open(Config["name_lookup_queue_file"])
+	if err != nil {
+		return
+	}
+	nameLookupMutex.Lock()
+	defer nameLookupMutex.Unlock()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		result := nameLookupLineRegexp.FindStringSubmatch(scanner.Text())
+		if len(result) == 0 {
+			continue
+		}
+		uuid := result[1]
+		// We might have learned this one from the price feed or an import in the meantime
+		if _, ok := lookupCardDB(uuid); ok {
+			continue
+		}
+		attempts, _ := strconv.Atoi(result[2])
+		next, _ := strconv.ParseInt(result[3], 10, 64)
+		nameLookupQueue[uuid] = nameLookup{uuid: uuid, attempts: attempts, next: time.Unix(next, 0)}
+	}
+	in.Close()
+	if unresolved := unresolvedNameLookups(); len(unresolved) > 0 {
+		fmt.Printf("There are %v card UUIDs we could not find names for. See /unresolved for the list.\n", len(unresolved))
+	}
+	scheduleNameLookup()
+}
+
+// Write out the queue so we pick up where we left off after a restart. The caller holds nameLookupMutex.
+func saveNameLookupQueue() {
+	queueFile := Config["name_lookup_queue_file"]
+	if queueFile == "" {
+		return
+	}
+	f, err := os.Create(queueFile)
+	if err != nil {
+		fmt.Printf("Could not create file %v for writing: %v\n", queueFile, err)
+		return
+	}
+	defer f.Close()
+	for _, l := range sortedNameLookups() {
+		fmt.Fprintf(f, "%v : %v : %v\n", l.uuid, l.attempts, l.next.Unix())
+	}
+}
+
+// How many times we'll ask about a UUID before giving up on it
+func nameLookupMaxRetries() int {
+	max, err := strconv.Atoi(Config["name_lookup_max_retries"])
+	if err != nil || max < 1 {
+		return 1
+	}
+	return max
+}
+
+// How long to wait after a failed lookup. This doubles with every attempt.
+func nameLookupBackoff(attempts int) time.Duration {
+	backoff := nameLookupTimerPeriod
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= nameLookupMaxBackoff {
+			return nameLookupMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// Queue up a UUID so we can ask the remote name lookup service about it later
+func addRemoteNameLookup(uuid string) {
+	if Config["remote_name_lookup"] != "true" {
+		return
+	}
+	nameLookupMutex.Lock()
+	defer nameLookupMutex.Unlock()
+	if _, ok := nameLookupQueue[uuid]; ok {
+		return
+	}
+	nameLookupQueue[uuid] = nameLookup{uuid: uuid, next: time.Now().Add(nameLookupTimerPeriod)}
+	saveNameLookupQueue()
+	scheduleNameLookup()
+}
+
+// Take a UUID out of the queue once we know its name
+func dropNameLookup(uuid string) {
+	nameLookupMutex.Lock()
+	defer nameLookupMutex.Unlock()
+	if _, ok := nameLookupQueue[uuid]; !ok {
+		return
+	}
+	delete(nameLookupQueue, uuid)
+	saveNameLookupQueue()
+}
+
+// Set the lookup timer to go off when the next UUID in the queue is due. The caller holds nameLookupMutex.
+func scheduleNameLookup() {
+	if nameLookupCacheTimer != nil {
+		nameLookupCacheTimer.Stop()
+	}
+	max := nameLookupMaxRetries()
+	var next time.Time
+	for _, l := range nameLookupQueue {
+		if l.attempts >= max {
+			continue
+		}
+		if next.IsZero() || l.next.Before(next) {
+			next = l.next
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	wait := time.Until(next)
+	if wait < 0 {
+		wait = 0
+	}
+	nameLookupCacheTimer = time.AfterFunc(wait, doRemoteNameLookup)
+}
+
+// Ask the remote name lookup service about every UUID that's due, a batch at a time.
+// We send 'name_lookup_url?uuid1,uuid2,...' and expect back 'uuid : name' lines. If we only
+// asked about a single UUID, a bare name as the whole response is fine too.
+func doRemoteNameLookup() {
+	if Config["remote_name_lookup"] != "true" {
+		return
+	}
+	batchSize, err := strconv.Atoi(Config["name_lookup_batch_size"])
+	if err != nil || batchSize < 1 {
+		batchSize = 1
+	}
+	max := nameLookupMaxRetries()
+	now := time.Now()
+	var due []string
+	nameLookupMutex.Lock()
+	for _, l := range sortedNameLookups() {
+		if l.attempts < max && !l.next.After(now) {
+			due = append(due, l.uuid)
+		}
+	}
+	nameLookupMutex.Unlock()
+
+	for start := 0; start < len(due); start += batchSize {
+		end := start + batchSize
+		if end > len(due) {
+			end = len(due)
+		}
+		batch := due[start:end]
+		lookupURL := fmt.Sprintf("%v?%v", Config["name_lookup_url"], strings.Join(batch, ","))
+		names := make(map[string]string)
+		body, err := grabFromURL(context.Background(), lookupURL)
+		if err != nil {
+			Debug(Config["debug_card_db"], "[doRemoteNameLookup] Encountered error looking up %v UUIDs: %v", len(batch), err)
+		} else {
+			names = parseNameLookupResponse(body, batch)
+		}
+		// We don't hold the lock while we wait on the lookup service, and learnCardName
+		// takes it itself, so only hold it while we're changing the queue
+		nameLookupMutex.Lock()
+		for _, uuid := range batch {
+			if _, ok := names[uuid]; ok {
+				delete(nameLookupQueue, uuid)
+				continue
+			}
+			l, ok := nameLookupQueue[uuid]
+			if !ok {
+				// Somebody else learned this one while we were asking
+				continue
+			}
+			l.attempts++
+			l.next = now.Add(nameLookupBackoff(l.attempts))
+			nameLookupQueue[uuid] = l
+			if l.attempts >= max {
+				fmt.Printf("Giving up on looking up the name for UUID %v after %v attempts\n", uuid, l.attempts)
+			}
+		}
+		nameLookupMutex.Unlock()
+		for _, uuid := range batch {
+			if name, ok := names[uuid]; ok {
+				learnCardName(uuid, name)
+			}
+		}
+	}
+	nameLookupMutex.Lock()
+	defer nameLookupMutex.Unlock()
+	saveNameLookupQueue()
+	scheduleNameLookup()
+}
+
+// Turn the body we got back from the name lookup service into a map of UUIDs to names
+func parseNameLookupResponse(body string, asked []string) map[string]string {
+	names := make(map[string]string)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	for _, line := range lines {
+		result := cardDBLineRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if len(result) == 0 {
+			continue
+		}
+		names[result[1]] = strings.TrimSpace(result[2])
+	}
+	if len(names) == 0 && len(asked) == 1 && len(lines) == 1 && strings.TrimSpace(lines[0]) != "" {
+		names[asked[0]] = strings.TrimSpace(lines[0])
+	}
+	return names
+}
+
+// Queue entries sorted by UUID so the queue file and reports come out the same every time.
+// The caller holds nameLookupMutex.
+func sortedNameLookups() []nameLookup {
+	uuids := make([]string, 0, len(nameLookupQueue))
+	for uuid := range nameLookupQueue {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	lookups := make([]nameLookup, len(uuids))
+	for i, uuid := range uuids {
+		lookups[i] = nameLookupQueue[uuid]
+	}
+	return lookups
+}
+
+// The UUIDs we've given up on. The caller holds nameLookupMutex.
+func unresolvedNameLookups() []nameLookup {
+	var unresolved []nameLookup
+	max := nameLookupMaxRetries()
+	for _, l := range sortedNameLookups() {
+		if l.attempts >= max {
+			unresolved = append(unresolved, l)
+		}
+	}
+	return unresolved
+}
+
+// Report on UUIDs that never resolved along with the ones still waiting on a retry
+func unresolvedNamesReport() string {
+	nameLookupMutex.Lock()
+	defer nameLookupMutex.Unlock()
+	report := ""
+	max := nameLookupMaxRetries()
+	unresolved := unresolvedNameLookups()
+	report += fmt.Sprintf("%v card UUIDs could not be resolved to names:\n", len(unresolved))
+	for _, l := range unresolved {
+		report += fmt.Sprintf("\t%v (%v attempts) [Qty: %v]\n", l.uuid, l.attempts, getCardCount(l.uuid))
+	}
+	pending := len(nameLookupQueue) - len(unresolved)
+	report += fmt.Sprintf("%v card UUIDs are waiting on a name lookup:\n", pending)
+	for _, l := range sortedNameLookups() {
+		if l.attempts < max {
+			report += fmt.Sprintf("\t%v (%v attempts, next at %v)\n", l.uuid, l.attempts, l.next.Format(time.UnixDate))
+		}
+	}
+	return report
+}
+
+func unresolvedNamesRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print unresolved card names received.")
+	report := unresolvedNamesReport()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
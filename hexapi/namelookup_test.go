@@ -0,0 +1,68 @@
+// Test cases for the remote name lookup queue
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNameLookupResponse(t *testing.T) {
+	for _, c := range []struct {
+		body  string
+		asked []string
+		want  map[string]string
+	}{
+		{"Yeti\n", []string{"aaa"}, map[string]string{"aaa": "Yeti"}},
+		{"aaa : Yeti\nbbb : Baby Yeti\n", []string{"aaa", "bbb"}, map[string]string{"aaa": "Yeti", "bbb": "Baby Yeti"}},
+		{"aaa : Yeti\n", []string{"aaa", "bbb"}, map[string]string{"aaa": "Yeti"}},
+		{"Yeti\n", []string{"aaa", "bbb"}, map[string]string{}},
+		{"", []string{"aaa"}, map[string]string{}},
+	} {
+		got := parseNameLookupResponse(c.body, c.asked)
+		if len(got) != len(c.want) {
+			t.Errorf("parseNameLookupResponse(%q) == %v but we expected %v", c.body, got, c.want)
+			continue
+		}
+		for k, v := range c.want {
+			if got[k] != v {
+				t.Errorf("parseNameLookupResponse(%q)[%v] == %q but we expected %q", c.body, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestNameLookupBackoff(t *testing.T) {
+	for _, c := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, nameLookupTimerPeriod},
+		{1, nameLookupTimerPeriod},
+		{2, nameLookupTimerPeriod * 2},
+		{4, nameLookupTimerPeriod * 8},
+		{100, nameLookupMaxBackoff},
+	} {
+		got := nameLookupBackoff(c.attempts)
+		if got != c.want {
+			t.Errorf("nameLookupBackoff(%v) == %v but we expected %v", c.attempts, got, c.want)
+		}
+	}
+}
+
+func TestRecordCardNameDropsLookup(t *testing.T) {
+	Config = make(map[string]string)
+	Config["remote_name_lookup"] = "true"
+	uuid := "0f1e2d3c-0000-0000-0000-000000000001"
+	cardCollection[uuid] = Card{name: uuid, uuid: uuid}
+	addRemoteNameLookup(uuid)
+	defer nameLookupCacheTimer.Stop()
+	// The price feed tells us the name before the lookup service does
+	recordCardName(uuid, "Feed Card")
+	if _, ok := nameLookupQueue[uuid]; ok {
+		t.Errorf("%v is still queued for a name lookup after recordCardName", uuid)
+	}
+	if name := cardCollection[uuid].name; name != "Feed Card" {
+		t.Errorf("collection card is named %v after recordCardName but we expected 'Feed Card'", name)
+	}
+}
@@ -0,0 +1,162 @@
+// Overwrite discrepancies: compare what we thought we had with what an Overwrite says we have
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// overwriteDiscrepancy is a card whose count (or EA count) wasn't what the Overwrite said it was
+type overwriteDiscrepancy struct {
+	uuid     string
+	name     string
+	before   int
+	after    int
+	eaBefore int
+	eaAfter  int
+	cause    string
+}
+
+// What we had when we logged out. Logging out zeroes the collection, so this is what the next
+// Overwrite gets compared against.
+var overwriteBaseline map[string][2]int
+
+// How many Update messages we've had for each nature since its last Overwrite (or since startup)
+var updatesSinceOverwrite = make(map[string]int)
+
+// The last report, for the /discrepancies page
+var lastOverwriteReport string
+
+// Hold on to everything we think we have before a logout throws it away. If we log out twice
+// without an Overwrite in between, the first one is what we want.
+func saveOverwriteBaseline() {
+	if overwriteBaseline == nil {
+		overwriteBaseline = snapshotCounts("")
+	}
+}
+
+// Counts for every card of a nature (or every card if the nature is blank)
+func snapshotCounts(nature string) map[string][2]int {
+	counts := make(map[string][2]int)
+	for uuid, c := range cardCollection {
+		if nature == "" || c.nature == nature {
+			counts[uuid] = [2]int{c.qty, c.eaqty}
+		}
+	}
+	return counts
+}
+
+// What we believed before an Overwrite of this nature. If we logged out since the last one, that's
+// what we had at logout.
+func overwriteBefore(nature string) map[string][2]int {
+	if overwriteBaseline == nil {
+		return snapshotCounts(nature)
+	}
+	before := make(map[string][2]int)
+	for uuid, counts := range overwriteBaseline {
+		if cardCollection[uuid].nature == nature {
+			before[uuid] = counts
+			delete(overwriteBaseline, uuid)
+		}
+	}
+	if len(overwriteBaseline) == 0 {
+		overwriteBaseline = nil
+	}
+	return before
+}
+
+// Our best guess as to why a card's count was off
+func discrepancyCause(uuid string, nature string, before int, after int) string {
+	if kind := uncorrectedPickDiscrepancy(uuid); kind != "" {
+		return fmt.Sprintf("unreconciled draft pick (%v)", kind)
+	}
+	if updatesSinceOverwrite[nature] == 0 {
+		return "collection cache out of date"
+	}
+	added, removed := "CardsAdded", "CardsRemoved"
+	if nature == "Inventory" {
+		added, removed = "ItemsAdded", "ItemsRemoved"
+	}
+	if after > before {
+		return fmt.Sprintf("missed %v update", added)
+	}
+	return fmt.Sprintf("missed %v update", removed)
+}
+
+// Compare what we had before an Overwrite with what we have now that it's done
+func overwriteDiscrepancies(before map[string][2]int, nature string) []overwriteDiscrepancy {
+	var found []overwriteDiscrepancy
+	seen := make(map[string]bool)
+	check := func(uuid string) {
+		if seen[uuid] {
+			return
+		}
+		seen[uuid] = true
+		c := cardCollection[uuid]
+		if c.nature != nature {
+			return
+		}
+		b := before[uuid]
+		if b[0] == c.qty && b[1] == c.eaqty {
+			return
+		}
+		cause := discrepancyCause(uuid, nature, b[0]+b[1], c.qty+c.eaqty)
+		found = append(found, overwriteDiscrepancy{uuid: uuid, name: c.name, before: b[0], after: c.qty, eaBefore: b[1], eaAfter: c.eaqty, cause: cause})
+	}
+	for uuid := range before {
+		check(uuid)
+	}
+	for uuid := range cardCollection {
+		check(uuid)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].name != found[j].name {
+			return found[i].name < found[j].name
+		}
+		return found[i].uuid < found[j].uuid
+	})
+	return found
+}
+
+func sprintOverwriteDiscrepancies(found []overwriteDiscrepancy, nature string) string {
+	if len(found) == 0 {
+		return fmt.Sprintf("%v Overwrite matched everything we had\n", nature)
+	}
+	s := fmt.Sprintf("==========================  %v OVERWRITE DISCREPANCIES  ==========================\n", nature)
+	causes := make(map[string]int)
+	for _, d := range found {
+		s += fmt.Sprintf("\t'%v' had %v (%v EA), Overwrite says %v (%v EA): %v\n", d.name, d.before, d.eaBefore, d.after, d.eaAfter, d.cause)
+		causes[d.cause]++
+	}
+	s += fmt.Sprintf("%v cards were off: %v\n", len(found), sprintCounts(causes))
+	return s
+}
+
+// Report on an Overwrite once it's been applied
+func reportOverwrite(before map[string][2]int, nature string) {
+	updates := updatesSinceOverwrite[nature]
+	found := overwriteDiscrepancies(before, nature)
+	updatesSinceOverwrite[nature] = 0
+	// An Overwrite with nothing to compare against (no cache and no updates yet) isn't worth talking about
+	total := 0
+	for _, counts := range before {
+		total += counts[0] + counts[1]
+	}
+	if total == 0 && updates == 0 {
+		return
+	}
+	lastOverwriteReport = sprintOverwriteDiscrepancies(found, nature)
+	fmt.Print(lastOverwriteReport)
+}
+
+func discrepanciesRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print the last Overwrite discrepancy report received.")
+	report := lastOverwriteReport
+	if report == "" {
+		report = "No Overwrite to compare against yet\n"
+	}
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
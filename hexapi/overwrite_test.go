@@ -0,0 +1,44 @@
+// Test cases for the Overwrite discrepancy report
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverwriteDiscrepancies(t *testing.T) {
+	uuids := testDraftSetup(t, 4)
+	resetPickLedger()
+	overwriteBaseline = nil
+	updatesSinceOverwrite = make(map[string]int)
+	savedSleep := sleep
+	sleep = func(time.Duration) {}
+	defer func() { sleep = savedSleep }()
+	for i, uuid := range uuids {
+		c := cardCollection[uuid]
+		c.qty, c.eaqty, c.nature = i, 0, "Card"
+		cardCollection[uuid] = c
+	}
+
+	// Nothing's come in since we loaded, so anything off means the cache was out of date
+	before := overwriteBefore("Card")
+	c := cardCollection[uuids[1]]
+	c.qty = 3
+	cardCollection[uuids[1]] = c
+	found := overwriteDiscrepancies(before, "Card")
+	if len(found) != 1 || found[0].uuid != uuids[1] || found[0].before != 1 || found[0].after != 3 || found[0].cause != "collection cache out of date" {
+		t.Errorf("overwriteDiscrepancies() == %+v but we expected Card 1 going from 1 to 3 with an out of date cache", found)
+	}
+
+	// Once we've seen updates, a count that's gone down means we missed a removal. A logout in
+	// between shouldn't stop us seeing it.
+	updatesSinceOverwrite["Card"] = 1
+	logoutEvent("")
+	collectionOrInventoryEvent(testCollectionMessage("Overwrite", uuids[3], 2))
+	want := "3 cards were off: missed CardsRemoved update 3\n"
+	if !strings.HasSuffix(lastOverwriteReport, want) {
+		t.Errorf("Overwrite after logging out gave us the report:\n%v\nbut we expected it to end with %q", lastOverwriteReport, want)
+	}
+}
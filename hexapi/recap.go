@@ -0,0 +1,181 @@
+// End of draft recap: what we took, what we passed up and whether the draft paid for itself
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RecapPick is one of our picks along with the best cards we passed up to make it
+type RecapPick struct {
+	Round          int        `json:"round"`
+	Pick           int        `json:"pick"`
+	Size           int        `json:"size"`
+	Card           DraftCard  `json:"card"`
+	BestPlat       *DraftCard `json:"best_plat_passed,omitempty"`
+	BestGold       *DraftCard `json:"best_gold_passed,omitempty"`
+	BestCollection *DraftCard `json:"best_collection_passed,omitempty"`
+}
+
+// DraftRecap sums up a whole draft
+type DraftRecap struct {
+	DraftID     string      `json:"draft_id"`
+	Format      string      `json:"format"`
+	Started     time.Time   `json:"started"`
+	Ended       time.Time   `json:"ended"`
+	Picks       []RecapPick `json:"picks"`
+	PoolPlat    int         `json:"pool_plat"`
+	PoolGold    int         `json:"pool_gold"`
+	CostPlat    int         `json:"cost_plat"`
+	CostGold    int         `json:"cost_gold"`
+	ProfitPlat  int         `json:"profit_plat"`
+	ProfitGold  int         `json:"profit_gold"`
+	Wheeled     []DraftCard `json:"wheeled"`
+	TakenFromUs []DraftCard `json:"taken_from_us"`
+}
+
+// Turn a DraftCard back into a Card so we can use the same comparisons we use while drafting
+func (c DraftCard) asCard() Card {
+	return Card{name: c.Name, uuid: c.UUID, rarity: c.Rarity, qty: c.Qty, plat: c.Plat, gold: c.Gold}
+}
+
+// Work out the best plat, gold and collection picks left in 'cards' once 'picked' is taken out
+func bestPassed(cards []DraftCard, picked DraftCard) (plat, gold, collection *DraftCard) {
+	skipped := false
+	for i := range cards {
+		c := cards[i]
+		if !skipped && c.UUID == picked.UUID {
+			skipped = true
+			continue
+		}
+		if plat == nil {
+			plat, gold, collection = &cards[i], &cards[i], &cards[i]
+			continue
+		}
+		if mostPlat(plat.asCard(), c.asCard()).uuid == c.UUID {
+			plat = &cards[i]
+		}
+		if mostGold(gold.asCard(), c.asCard()).uuid == c.UUID {
+			gold = &cards[i]
+		}
+		if leastQty(collection.asCard(), c.asCard()).uuid == c.UUID {
+			collection = &cards[i]
+		}
+	}
+	return
+}
+
+// Build the recap for a draft
+func (d *Draft) recap() DraftRecap {
+	r := DraftRecap{DraftID: d.ID, Format: d.Format, Started: d.Started, Ended: d.Ended}
+	for i, p := range d.Picks {
+		rp := RecapPick{Round: p.Round, Pick: i + 1, Size: p.Size, Card: p.Card}
+		if pack := d.packFor(p.Round, p.Size); pack != nil {
+			rp.BestPlat, rp.BestGold, rp.BestCollection = bestPassed(pack.Cards, p.Card)
+		}
+		r.Picks = append(r.Picks, rp)
+	}
+	for _, pack := range d.Packs {
+		r.Wheeled = append(r.Wheeled, pack.Wheeled...)
+		r.TakenFromUs = append(r.TakenFromUs, pack.Missing...)
+	}
+	r.PoolPlat, r.PoolGold = d.poolValue()
+	r.CostPlat = d.PackCost * d.Rounds
+	r.CostGold = d.PackGoldCost * d.Rounds
+	r.ProfitPlat = r.PoolPlat - r.CostPlat
+	r.ProfitGold = r.PoolGold - r.CostGold
+	return r
+}
+
+func sprintPassed(c *DraftCard, value string) string {
+	if c == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%v (%v)", c.Name, value)
+}
+
+// Console version of the recap
+func (r DraftRecap) String() string {
+	s := "==========================         DRAFT RECAP          ==========================\n"
+	s += fmt.Sprintf("%v draft %v started %v\n", r.Format, r.DraftID, r.Started.Format(time.UnixDate))
+	for _, p := range r.Picks {
+		s += fmt.Sprintf("R%v P%-2d [%2d] %-30v %4dp %6dg", p.Round, p.Pick, p.Size, p.Card.Name, p.Card.Plat, p.Card.Gold)
+		if p.BestPlat != nil {
+			s += fmt.Sprintf(" | passed: plat %v, gold %v, collection %v",
+				sprintPassed(p.BestPlat, fmt.Sprintf("%vp", p.BestPlat.Plat)),
+				sprintPassed(p.BestGold, fmt.Sprintf("%vg", p.BestGold.Gold)),
+				sprintPassed(p.BestCollection, fmt.Sprintf("qty %v", p.BestCollection.Qty)))
+		}
+		s += "\n"
+	}
+	s += fmt.Sprintf("Pool value: %vp (%vg). Packs cost %vp (%vg). Profit: %vp (%vg)\n", r.PoolPlat, r.PoolGold, r.CostPlat, r.CostGold, r.ProfitPlat, r.ProfitGold)
+	s += fmt.Sprintf("Wheeled: %v\n", joinDraftCardNames(r.Wheeled))
+	s += fmt.Sprintf("Taken from us: %v\n", joinDraftCardNames(r.TakenFromUs))
+	s += "==========================         DRAFT RECAP          ==========================\n"
+	return s
+}
+
+// Markdown version of the recap, suitable for pasting into chat or a forum post
+func (r DraftRecap) markdown() string {
+	s := fmt.Sprintf("# %v draft recap (%v)\n\n", r.Format, r.Started.Format("2006-01-02 15:04"))
+	s += "| Round | Pick | Card | Plat | Gold | Best plat passed | Best gold passed | Best collection pick passed |\n"
+	s += "|---|---|---|---|---|---|---|---|\n"
+	for _, p := range r.Picks {
+		s += fmt.Sprintf("| %v | %v | %v | %v | %v |", p.Round, p.Pick, p.Card.Name, p.Card.Plat, p.Card.Gold)
+		if p.BestPlat != nil {
+			s += fmt.Sprintf(" %v | %v | %v |\n",
+				sprintPassed(p.BestPlat, fmt.Sprintf("%vp", p.BestPlat.Plat)),
+				sprintPassed(p.BestGold, fmt.Sprintf("%vg", p.BestGold.Gold)),
+				sprintPassed(p.BestCollection, fmt.Sprintf("qty %v", p.BestCollection.Qty)))
+		} else {
+			s += " - | - | - |\n"
+		}
+	}
+	s += fmt.Sprintf("\n**Pool value:** %vp (%vg)  \n**Pack cost:** %vp (%vg)  \n**Profit:** %vp (%vg)\n", r.PoolPlat, r.PoolGold, r.CostPlat, r.CostGold, r.ProfitPlat, r.ProfitGold)
+	s += fmt.Sprintf("\n**Wheeled:** %v\n", markdownCardList(r.Wheeled))
+	s += fmt.Sprintf("\n**Taken from us:** %v\n", markdownCardList(r.TakenFromUs))
+	return s
+}
+
+func markdownCardList(cards []DraftCard) string {
+	if len(cards) == 0 {
+		return "none"
+	}
+	names := make([]string, len(cards))
+	for i, c := range cards {
+		names[i] = c.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Print the recap and write out the JSON and Markdown versions next to the draft log
+func (d *Draft) writeRecap() {
+	r := d.recap()
+	fmt.Print(r.String())
+	if Config["draft_log_dir"] == "" {
+		return
+	}
+	if err := os.MkdirAll(Config["draft_log_dir"], 0770); err != nil {
+		fmt.Printf("Could not create draft log directory %v: %v\n", Config["draft_log_dir"], err)
+		return
+	}
+	base := filepath.Join(Config["draft_log_dir"], fmt.Sprintf("recap-%v", d.ID))
+	blob, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		fmt.Printf("Could not encode recap for draft %v: %v\n", d.ID, err)
+		return
+	}
+	if err := ioutil.WriteFile(base+".json", blob, 0660); err != nil {
+		fmt.Printf("Could not write draft recap %v: %v\n", base+".json", err)
+	}
+	if err := ioutil.WriteFile(base+".md", []byte(r.markdown()), 0660); err != nil {
+		fmt.Printf("Could not write draft recap %v: %v\n", base+".md", err)
+	}
+	fmt.Printf("Draft recap written to %v.json and %v.md\n", base, base)
+}
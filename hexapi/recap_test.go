@@ -0,0 +1,111 @@
+// Test cases for draft recaps
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A one round draft where we take the most valuable card out of the first pack and then see nine
+// of the cards come back
+func testRecapDraft(t *testing.T) (*Draft, []string) {
+	uuids := testDraftSetup(t, 17)
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[16]))
+	draftPackEvent(testDraftPackMessage(uuids[:9]))
+	draftCardPickedEvent(testDraftPickMessage(uuids[8]))
+	d := currentDraft
+	d.Rounds, d.PackCost, d.PackGoldCost = 1, 10, 1000
+	return d, uuids
+}
+
+func TestBestPassed(t *testing.T) {
+	cards := []DraftCard{
+		{UUID: "a", Name: "A", Qty: 4, Plat: 10, Gold: 1000},
+		{UUID: "b", Name: "B", Qty: 0, Plat: 1, Gold: 50},
+		{UUID: "c", Name: "C", Qty: 2, Plat: 5, Gold: 2000},
+		{UUID: "d", Name: "D", Qty: 1, Plat: 3, Gold: 10},
+	}
+	plat, gold, collection := bestPassed(cards, cards[0])
+	if plat == nil || plat.UUID != "c" {
+		t.Errorf("best plat passed was %v but we expected C", plat)
+	}
+	if gold == nil || gold.UUID != "c" {
+		t.Errorf("best gold passed was %v but we expected C", gold)
+	}
+	if collection == nil || collection.UUID != "b" {
+		t.Errorf("best collection pick passed was %v but we expected B", collection)
+	}
+	plat, _, _ = bestPassed(cards[:1], cards[0])
+	if plat != nil {
+		t.Errorf("best plat passed from a single card pack was %v but we expected nothing", plat)
+	}
+}
+
+func TestRecapMarkdown(t *testing.T) {
+	d, _ := testRecapDraft(t)
+	md := d.recap().markdown()
+	for _, want := range []string{
+		"| Round | Pick | Card | Plat | Gold | Best plat passed | Best gold passed | Best collection pick passed |\n",
+		"| 1 | 1 | Card 16 | 17 | 1700 | Card 15 (16p) | Card 15 (1600g) | Card 15 (qty 0) |\n",
+		"| 1 | 2 | Card 8 | 9 | 900 | Card 7 (8p) | Card 7 (800g) | Card 7 (qty 0) |\n",
+		"**Pool value:** 26p (2600g)  \n**Pack cost:** 10p (1000g)  \n**Profit:** 16p (1600g)\n",
+		"\n**Wheeled:** Card 0, Card 1, Card 2, Card 3, Card 4, Card 5, Card 6, Card 7, Card 8\n",
+		"\n**Taken from us:** Card 9, Card 10, Card 11, Card 12, Card 13, Card 14, Card 15\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown recap does not contain %q:\n%v", want, md)
+		}
+	}
+	if got := (DraftRecap{}).markdown(); !strings.Contains(got, "**Wheeled:** none\n") {
+		t.Errorf("markdown recap of an empty draft == %q but we expected no wheeled cards", got)
+	}
+}
+
+func TestRecapJSON(t *testing.T) {
+	d, uuids := testRecapDraft(t)
+	d.writeRecap()
+
+	base := filepath.Join(Config["draft_log_dir"], "recap-"+d.ID)
+	blob, err := ioutil.ReadFile(base + ".json")
+	if err != nil {
+		t.Fatalf("could not read JSON recap: %v", err)
+	}
+	var r DraftRecap
+	if err := json.Unmarshal(blob, &r); err != nil {
+		t.Fatalf("could not decode JSON recap: %v", err)
+	}
+	// Times lose their monotonic clock reading on the way through JSON, so compare them separately
+	want := d.recap()
+	if !r.Started.Equal(want.Started) || !r.Ended.Equal(want.Ended) {
+		t.Errorf("JSON recap runs from %v to %v but we expected %v to %v", r.Started, r.Ended, want.Started, want.Ended)
+	}
+	r.Started, r.Ended = want.Started, want.Ended
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("JSON recap decodes to %+v but we expected %+v", r, want)
+	}
+	if len(r.Picks) != 2 || r.Picks[0].Card.UUID != uuids[16] || r.Picks[0].BestPlat == nil || r.Picks[0].BestPlat.UUID != uuids[15] {
+		t.Errorf("JSON recap picks are %+v but we expected Card 16 passing up Card 15", r.Picks)
+	}
+	if r.ProfitPlat != 16 || r.ProfitGold != 1600 {
+		t.Errorf("JSON recap profit is %vp (%vg) but we expected 16p (1600g)", r.ProfitPlat, r.ProfitGold)
+	}
+	for _, key := range []string{`"draft_id"`, `"best_plat_passed"`, `"taken_from_us"`} {
+		if !strings.Contains(string(blob), key) {
+			t.Errorf("JSON recap does not contain %v:\n%s", key, blob)
+		}
+	}
+
+	md, err := ioutil.ReadFile(base + ".md")
+	if err != nil {
+		t.Fatalf("could not read markdown recap: %v", err)
+	}
+	if string(md) != r.markdown() {
+		t.Errorf("markdown recap on disk is\n%s\nbut we expected\n%v", md, r.markdown())
+	}
+}
@@ -0,0 +1,221 @@
+// Reconcile the cards we draft against the Collection updates Hex sends for them
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pendingPick is a card we drafted. We bump the count as soon as it's picked so pack output is
+// right, so the Collection update that follows needs to be matched up with it and not counted again.
+type pendingPick struct {
+	uuid    string
+	draftID string
+	picked  time.Time
+	matched time.Time
+}
+
+// pickDiscrepancy is something that didn't line up: a pick that never got its update ("unmatched"),
+// an update for a pick that was already matched ("double"), or a pick still waiting when an
+// Overwrite came in ("settled"). Before and after are the counts either side of the next Overwrite.
+type pickDiscrepancy struct {
+	kind      string
+	pick      pendingPick
+	seen      time.Time
+	corrected bool
+	before    int
+	after     int
+}
+
+// Picks waiting on their Collection update, picks that have been matched recently (so we can spot
+// a second update for them), and everything that didn't add up this session
+var pendingPicks []pendingPick
+var matchedPicks []pendingPick
+var pickDiscrepancies []pickDiscrepancy
+var matchedPickCount int
+
+// pickLedgerMutex guards the picks and discrepancies above. Picks, Collection updates and the
+// reconcile page all come in on the API server's goroutines.
+var pickLedgerMutex sync.Mutex
+
+// How long we wait for a pick's Collection update before calling it unmatched
+func pickReconcileTimeout() time.Duration {
+	secs, err := strconv.Atoi(Config["pick_reconcile_timeout"])
+	if err != nil || secs < 1 {
+		secs = 120
+	}
+	return time.Second * time.Duration(secs)
+}
+
+// Start over with an empty ledger
+func resetPickLedger() {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	pendingPicks = nil
+	matchedPicks = nil
+	pickDiscrepancies = nil
+	matchedPickCount = 0
+}
+
+// Note a drafted card we're expecting a Collection update for
+func addPendingPick(uuid string, draftID string) {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	expirePendingPicks()
+	pendingPicks = append(pendingPicks, pendingPick{uuid: uuid, draftID: draftID, picked: now()})
+}
+
+// Anything that's waited too long for its update is unmatched. Matches older than the timeout
+// are dropped since a second update that late is more likely something else entirely. The caller
+// holds pickLedgerMutex.
+func expirePendingPicks() {
+	timeout := pickReconcileTimeout()
+	var stillPending []pendingPick
+	for _, p := range pendingPicks {
+		if now().Sub(p.picked) > timeout {
+			Debug(Config["debug_reconcile"], "[expirePendingPicks] No Collection update for '%v' after %v", getCardNameFromUUID(p.uuid), timeout)
+			pickDiscrepancies = append(pickDiscrepancies, pickDiscrepancy{kind: "unmatched", pick: p, seen: now()})
+			continue
+		}
+		stillPending = append(stillPending, p)
+	}
+	pendingPicks = stillPending
+	var stillMatched []pendingPick
+	for _, p := range matchedPicks {
+		if now().Sub(p.matched) <= timeout {
+			stillMatched = append(stillMatched, p)
+		}
+	}
+	matchedPicks = stillMatched
+}
+
+// Match a Collection update for 'count' copies of a card against our pending picks. We hand back
+// how many of them are new to us and still need adding to the collection.
+func reconcilePickUpdate(uuid string, count int) int {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	expirePendingPicks()
+	for count > 0 {
+		i := pendingPickIndex(pendingPicks, uuid)
+		if i < 0 {
+			break
+		}
+		p := pendingPicks[i]
+		p.matched = now()
+		pendingPicks = append(pendingPicks[:i], pendingPicks[i+1:]...)
+		matchedPicks = append(matchedPicks, p)
+		matchedPickCount++
+		count--
+		Debug(Config["debug_reconcile"], "[reconcilePickUpdate] Matched Collection update for '%v' to our pick", getCardNameFromUUID(uuid))
+	}
+	// An update for a card we just matched looks like the same pick twice. We count it anyway
+	// (it could be a real copy from somewhere else) and let the next Overwrite sort it out.
+	if count > 0 {
+		if i := pendingPickIndex(matchedPicks, uuid); i >= 0 {
+			p := matchedPicks[i]
+			matchedPicks = append(matchedPicks[:i], matchedPicks[i+1:]...)
+			pickDiscrepancies = append(pickDiscrepancies, pickDiscrepancy{kind: "double", pick: p, seen: now()})
+		}
+	}
+	return count
+}
+
+// Where the oldest entry for a card is in a list of picks (or -1 if it isn't)
+func pendingPickIndex(picks []pendingPick, uuid string) int {
+	for i, p := range picks {
+		if p.uuid == uuid {
+			return i
+		}
+	}
+	return -1
+}
+
+// An Overwrite tells us exactly what we have, so anything still pending is settled by it. We hand back
+// the counts of every card that didn't add up so we can see what the Overwrite changed them to.
+func settlePendingPicks() map[string]int {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	expirePendingPicks()
+	for _, p := range pendingPicks {
+		pickDiscrepancies = append(pickDiscrepancies, pickDiscrepancy{kind: "settled", pick: p, seen: now()})
+	}
+	pendingPicks = nil
+	matchedPicks = nil
+	before := make(map[string]int)
+	for _, d := range pickDiscrepancies {
+		if !d.corrected {
+			before[d.pick.uuid] = cardCollection[d.pick.uuid].qty
+		}
+	}
+	return before
+}
+
+// The kind of the latest discrepancy for a card that an Overwrite hasn't sorted out yet, or "" if there isn't one
+func uncorrectedPickDiscrepancy(uuid string) string {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	for i := len(pickDiscrepancies) - 1; i >= 0; i-- {
+		if d := pickDiscrepancies[i]; d.pick.uuid == uuid && !d.corrected {
+			return d.kind
+		}
+	}
+	return ""
+}
+
+// Once an Overwrite is done, record what happened to the cards that didn't add up
+func correctPickDiscrepancies(before map[string]int) {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	for i, d := range pickDiscrepancies {
+		if d.corrected {
+			continue
+		}
+		qty, ok := before[d.pick.uuid]
+		if !ok {
+			continue
+		}
+		pickDiscrepancies[i].corrected = true
+		pickDiscrepancies[i].before = qty
+		pickDiscrepancies[i].after = cardCollection[d.pick.uuid].qty
+		if qty != pickDiscrepancies[i].after {
+			fmt.Printf("Collection Overwrite corrected '%v' from %v to %v (%v draft pick)\n", getCardNameFromUUID(d.pick.uuid), qty, pickDiscrepancies[i].after, d.kind)
+		}
+	}
+}
+
+func (d pickDiscrepancy) String() string {
+	s := fmt.Sprintf("%-9v '%v' picked %v in draft %v", d.kind, getCardNameFromUUID(d.pick.uuid), d.pick.picked.Format(time.Stamp), d.pick.draftID)
+	if d.corrected {
+		s += fmt.Sprintf(" - Overwrite set it from %v to %v", d.before, d.after)
+	} else {
+		s += " - waiting on the next Overwrite"
+	}
+	return s
+}
+
+// Everything we know about how picks and Collection updates have lined up this session
+func reconciliationReport() string {
+	pickLedgerMutex.Lock()
+	defer pickLedgerMutex.Unlock()
+	expirePendingPicks()
+	s := "==========================    DRAFT PICK RECONCILIATION     ==========================\n"
+	s += fmt.Sprintf("%v picks matched to Collection updates, %v waiting, %v that didn't add up\n", matchedPickCount, len(pendingPicks), len(pickDiscrepancies))
+	for _, p := range pendingPicks {
+		s += fmt.Sprintf("\twaiting   '%v' picked %v in draft %v\n", getCardNameFromUUID(p.uuid), p.picked.Format(time.Stamp), p.draftID)
+	}
+	for _, d := range pickDiscrepancies {
+		s += fmt.Sprintf("\t%v\n", d)
+	}
+	return s
+}
+
+func reconcileRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print draft pick reconciliation received.")
+	report := reconciliationReport()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
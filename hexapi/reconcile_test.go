@@ -0,0 +1,73 @@
+// Test cases for draft pick reconciliation
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testCollectionMessage(action string, uuid string, count int) map[string]interface{} {
+	card := map[string]interface{}{"Guid": map[string]interface{}{"m_Guid": uuid}, "Count": float64(count), "Flags": ""}
+	if action == "Overwrite" {
+		return map[string]interface{}{"Message": "Collection", "Action": action, "Complete": []interface{}{card}}
+	}
+	return map[string]interface{}{"Message": "Collection", "Action": action, "CardsAdded": []interface{}{card}}
+}
+
+func TestPickReconciliation(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	Config["pick_reconcile_timeout"] = "60"
+	resetPickLedger()
+	clock := time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+	savedNow, savedSleep := now, sleep
+	now = func() time.Time { return clock }
+	sleep = func(time.Duration) {}
+	defer func() { now, sleep = savedNow, savedSleep }()
+	for _, uuid := range uuids {
+		c := cardCollection[uuid]
+		c.qty, c.nature = 0, "Card"
+		cardCollection[uuid] = c
+	}
+
+	// A pick followed by its update only counts once
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[0]))
+	collectionOrInventoryEvent(testCollectionMessage("Update", uuids[0], 1))
+	if qty := cardCollection[uuids[0]].qty; qty != 1 {
+		t.Errorf("qty for our matched pick is %v but we expected 1", qty)
+	}
+	// The same update again is a double match, and gets counted until an Overwrite says otherwise
+	collectionOrInventoryEvent(testCollectionMessage("Update", uuids[0], 1))
+	if qty := cardCollection[uuids[0]].qty; qty != 2 {
+		t.Errorf("qty after a second update is %v but we expected 2", qty)
+	}
+	// A pick that never gets an update goes unmatched once the timeout passes
+	draftPackEvent(testDraftPackMessage(uuids[1:]))
+	draftCardPickedEvent(testDraftPickMessage(uuids[1]))
+	clock = clock.Add(time.Minute * 2)
+	expirePendingPicks()
+	if len(pendingPicks) != 0 || len(pickDiscrepancies) != 2 {
+		t.Fatalf("%v picks pending and %v discrepancies but we expected 0 and 2", len(pendingPicks), len(pickDiscrepancies))
+	}
+	if pickDiscrepancies[0].kind != "double" || pickDiscrepancies[1].kind != "unmatched" {
+		t.Errorf("discrepancies are %v and %v but we expected double and unmatched", pickDiscrepancies[0].kind, pickDiscrepancies[1].kind)
+	}
+
+	// The Overwrite puts the counts right and marks both as corrected
+	collectionOrInventoryEvent(testCollectionMessage("Overwrite", uuids[0], 1))
+	if qty := cardCollection[uuids[0]].qty; qty != 1 {
+		t.Errorf("qty after the Overwrite is %v but we expected 1", qty)
+	}
+	for _, d := range pickDiscrepancies {
+		if !d.corrected {
+			t.Errorf("%v was not corrected by the Overwrite", d)
+		}
+	}
+	if d := pickDiscrepancies[0]; d.before != 2 || d.after != 1 {
+		t.Errorf("double match went from %v to %v but we expected 2 to 1", d.before, d.after)
+	}
+	if d := pickDiscrepancies[1]; d.before != 1 || d.after != 0 {
+		t.Errorf("unmatched pick went from %v to %v but we expected 1 to 0", d.before, d.after)
+	}
+}
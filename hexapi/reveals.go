@@ -0,0 +1,190 @@
+// Revealed cards: everything the opponent has shown us this game, and what each champion plays across games
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Zones a card has to have been in for us to have seen it: Chain, Shard, Crypt and Void
+const revealZones = 128 | 64 | 16 | 32
+
+// The cards each player has revealed this game, by card instance, so a card that goes from the
+// chain to the crypt only counts once. Instances are keyed by cardInstanceKey, which keeps copies
+// of a card apart even when the messages don't have an Id. Indexed like turnSummary, 0 for p1 and 1 for p2.
+// Guarded by gameMutex.
+var reveals [2]map[string]string
+
+func resetReveals() {
+	reveals = [2]map[string]string{make(map[string]string), make(map[string]string)}
+}
+
+// A card moved. If it's somewhere we can see it, it's been revealed.
+func noteReveal(p *Player, key string, gc gameCard) {
+	if gc.location&revealZones == 0 || gc.name == "" {
+		return
+	}
+	i := playerIndex(p)
+	if reveals[i] == nil {
+		reveals[i] = make(map[string]string)
+	}
+	reveals[i][key] = gc.name
+}
+
+// How many of each card a player has revealed
+func revealCounts(i int) map[string]int {
+	counts := make(map[string]int)
+	for _, name := range reveals[i] {
+		counts[name]++
+	}
+	return counts
+}
+
+// Which player is the opponent: whoever isn't playing the champion of the deck we saved last.
+// -1 if we can't tell, which includes mirror matches where both sides play our champion.
+func opponentIndex() int {
+	if currentGame.p1.champion.name == currentGame.p2.champion.name {
+		return -1
+	}
+	switch savedDeckChampion {
+	case "":
+	case currentGame.p1.champion.name:
+		return 1
+	case currentGame.p2.champion.name:
+		return 0
+	}
+	return -1
+}
+
+// Cards and counts, most copies first
+func sprintRevealCounts(counts map[string]int) string {
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	var cards []string
+	for _, name := range names {
+		cards = append(cards, fmt.Sprintf("%vx %v", counts[name], name))
+	}
+	return strings.Join(cards, ", ")
+}
+
+// What the opponent has revealed in the game in progress. If we don't know which side is ours,
+// we show both.
+func revealsReport() string {
+	if currentGame.p1.id == 0 {
+		return "No game in progress\n"
+	}
+	players := []int{0, 1}
+	if i := opponentIndex(); i >= 0 {
+		players = []int{i}
+	}
+	s := ""
+	for _, i := range players {
+		counts := revealCounts(i)
+		if len(counts) == 0 {
+			s += fmt.Sprintf("%v hasn't revealed anything yet\n", playerLabel(i))
+			continue
+		}
+		s += fmt.Sprintf("%v has revealed: %v\n", playerLabel(i), sprintRevealCounts(counts))
+	}
+	return s
+}
+
+// What the opponent revealed, to go in the match record
+func opponentReveals() map[string]int {
+	i := opponentIndex()
+	if i < 0 {
+		return nil
+	}
+	counts := revealCounts(i)
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+// What opponents have revealed across every recorded game, grouped by their champion. The filters
+// are the same ones games takes.
+func metaReport(filters map[string]string) string {
+	type cardSeen struct {
+		games  int
+		copies int
+	}
+	games := make(map[string]int)
+	seen := make(map[string]map[string]*cardSeen)
+	matches, err := filterMatches(readMatches(), filters)
+	if err != nil {
+		return fmt.Sprintf("%v\n", err)
+	}
+	for _, m := range matches {
+		_, theirs := m.sides()
+		if theirs == "" || len(m.OpponentReveals) == 0 {
+			continue
+		}
+		games[theirs]++
+		if seen[theirs] == nil {
+			seen[theirs] = make(map[string]*cardSeen)
+		}
+		for name, n := range m.OpponentReveals {
+			if seen[theirs][name] == nil {
+				seen[theirs][name] = &cardSeen{}
+			}
+			seen[theirs][name].games++
+			seen[theirs][name].copies += n
+		}
+	}
+	if len(games) == 0 {
+		return "No opponent reveals recorded yet\n"
+	}
+	var champions []string
+	for c := range games {
+		champions = append(champions, c)
+	}
+	sort.Strings(champions)
+	s := "==========================      WHAT OPPONENTS PLAY      ==========================\n"
+	for _, c := range champions {
+		s += fmt.Sprintf("%v (%v games):\n", c, games[c])
+		var names []string
+		for name := range seen[c] {
+			names = append(names, name)
+		}
+		cards := seen[c]
+		sort.Slice(names, func(i, j int) bool {
+			if cards[names[i]].games != cards[names[j]].games {
+				return cards[names[i]].games > cards[names[j]].games
+			}
+			return names[i] < names[j]
+		})
+		for _, name := range names {
+			s += fmt.Sprintf("\t%-40v seen in %v of %v games, %v copies\n", name, cards[name].games, games[c], cards[name].copies)
+		}
+	}
+	return s
+}
+
+func revealsRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print revealed cards received.")
+	gameMutex.Lock()
+	report := revealsReport()
+	gameMutex.Unlock()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
+
+func metaRequest(rw http.ResponseWriter, req *http.Request) {
+	filters := queryFilters(req)
+	fmt.Printf("Request for opponent reveals matching %v received.\n", filters)
+	report := metaReport(filters)
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
@@ -0,0 +1,110 @@
+// Test cases for tracking the cards the opponent reveals
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpponentReveals(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = filepath.Join(t.TempDir(), "match_history.jsonl")
+	Config["turn_summaries"] = "true"
+
+	for game := 0; game < 2; game++ {
+		noteSavedDeck("Yetis", "Uzume")
+		gameStartedEvent()
+		cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+		cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+		// Our own cards don't count
+		cardUpdatedEvent(testCardUpdatedMessage(10, "Yeti Spy", 1, 128, 2, 1))
+		// A Burn goes on the chain and then to the crypt, which is still just the one Burn
+		cardUpdatedEvent(testCardUpdatedMessage(20, "Burn", 2, 128, 0, 0))
+		cardUpdatedEvent(testCardUpdatedMessage(20, "Burn", 2, 16, 0, 0))
+		cardUpdatedEvent(testCardUpdatedMessage(21, "Burn", 2, 128, 0, 0))
+		cardUpdatedEvent(testCardUpdatedMessage(22, "Wild Shard", 2, 64, 0, 0))
+		// Cards in their hand haven't been revealed
+		cardUpdatedEvent(testCardUpdatedMessage(23, "Secret", 2, 2, 0, 0))
+		if game == 1 {
+			cardUpdatedEvent(testCardUpdatedMessage(24, "Crocodile Hunter", 2, 32, 3, 3))
+		}
+		if game == 0 {
+			want := map[string]int{"Burn": 2, "Wild Shard": 1}
+			if got := opponentReveals(); !reflect.DeepEqual(got, want) {
+				t.Errorf("opponentReveals() == %v but we expected %v", got, want)
+			}
+			if report := revealsReport(); report != "Fuzzuko has revealed: 2x Burn, 1x Wild Shard\n" {
+				t.Errorf("revealsReport() == %q", report)
+			}
+		}
+		gameEndedEvent(map[string]interface{}{"Winners": []interface{}{"Uzume"}, "Losers": []interface{}{"Fuzzuko"}})
+	}
+
+	matches := readMatches()
+	if len(matches) != 2 || matches[1].OpponentReveals["Crocodile Hunter"] != 1 {
+		t.Fatalf("match history is %+v but we expected two games with the Crocodile Hunter in the second", matches)
+	}
+	report := metaReport(map[string]string{})
+	for _, want := range []string{
+		"Fuzzuko (2 games):\n",
+		"\tBurn                                     seen in 2 of 2 games, 4 copies\n",
+		"\tCrocodile Hunter                         seen in 1 of 2 games, 1 copies\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("meta report does not contain %q:\n%v", want, report)
+		}
+	}
+}
+
+// Without an Id each copy of a card still counts once: three Burns through the chain and into the
+// crypt are three Burns, not one and not six
+func TestOpponentRevealsWithoutIds(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = filepath.Join(t.TempDir(), "match_history.jsonl")
+	send := func(name string, controller int, collection int) {
+		f := testCardUpdatedMessage(0, name, controller, collection, 0, 0)
+		delete(f, "Id")
+		cardUpdatedEvent(f)
+	}
+	noteSavedDeck("Yetis", "Uzume")
+	gameStartedEvent()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+	for i := 0; i < 3; i++ {
+		send("Burn", 2, 2)
+		send("Burn", 2, 128)
+		send("Burn", 2, 16)
+	}
+	send("Wild Shard", 2, 2)
+	send("Wild Shard", 2, 64)
+
+	want := map[string]int{"Burn": 3, "Wild Shard": 1}
+	if got := opponentReveals(); !reflect.DeepEqual(got, want) {
+		t.Errorf("opponentReveals() == %v but we expected %v", got, want)
+	}
+}
+
+// When both sides play our champion we can't tell whose reveals are whose
+func TestOpponentRevealsMirrorMatch(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = filepath.Join(t.TempDir(), "match_history.jsonl")
+	noteSavedDeck("Yetis", "Uzume")
+	gameStartedEvent()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Uzume", 2, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(20, "Burn", 2, 128, 0, 0))
+
+	if i := opponentIndex(); i != -1 {
+		t.Errorf("opponentIndex() == %v in a mirror match but we expected -1", i)
+	}
+	if got := opponentReveals(); got != nil {
+		t.Errorf("opponentReveals() == %v in a mirror match but we expected nothing", got)
+	}
+	gameEndedEvent(map[string]interface{}{"Winners": []interface{}{"Uzume"}, "Losers": []interface{}{"Uzume"}})
+	if report := metaReport(map[string]string{}); report != "No opponent reveals recorded yet\n" {
+		t.Errorf("a mirror match made it into the meta report:\n%v", report)
+	}
+}
@@ -0,0 +1,181 @@
+// Pick scoring: rank the cards in a draft pack by a weighted mix of value, need and wheel chances
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The things we look at when scoring a card, in the order we print them
+var scoreFactors = []string{"plat", "gold", "need", "wheel", "rarity", "rating"}
+
+// Weights for each pick profile. Any of these can be overridden with a 'pick_weights_<profile>'
+// config value like 'plat:2,need:0.5'. New profiles can be made the same way.
+var defaultPickProfiles = map[string]map[string]float64{
+	"collector":   {"plat": 1, "gold": 0.5, "need": 3, "wheel": 1, "rarity": 1, "rating": 0.5},
+	"profit":      {"plat": 3, "gold": 2, "need": 0, "wheel": 1, "rarity": 0.5, "rating": 0},
+	"competitive": {"plat": 0.5, "gold": 0.25, "need": 0.5, "wheel": 1, "rarity": 0.5, "rating": 3},
+}
+
+// How much each rarity is worth to the 'rarity' factor
+var rarityScores = map[string]float64{"L": 1, "E": 1, "R": 0.75, "U": 0.5, "C": 0.25}
+
+// Our own ratings for cards, keyed by name or UUID. Read from 'card_ratings_file'.
+var cardRatings = make(map[string]float64)
+
+// Lines in the ratings file look like 'name or uuid : rating'
+var cardRatingLineRegexp = regexp.MustCompile(`^(.+?)\s*:\s*(-?[0-9.]+)$`)
+
+// pickScore is a card's total score along with how much each factor contributed
+type pickScore struct {
+	card  Card
+	score float64
+	parts map[string]float64
+}
+
+// Read in our card ratings
+func readCardRatings() {
+	in, err := os.Open(Config["card_ratings_file"])
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		result := cardRatingLineRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if len(result) == 0 || strings.HasPrefix(result[1], "#") {
+			continue
+		}
+		rating, err := strconv.ParseFloat(result[2], 64)
+		if err != nil {
+			continue
+		}
+		cardRatings[result[1]] = rating
+	}
+}
+
+// Look up our rating for a card by UUID first, then by name
+func cardRating(c Card) float64 {
+	if r, ok := cardRatings[c.uuid]; ok {
+		return r
+	}
+	return cardRatings[c.name]
+}
+
+// Get the weights for a profile, applying any overrides from the config
+func pickWeights(profile string) map[string]float64 {
+	weights := make(map[string]float64)
+	for k, v := range defaultPickProfiles[profile] {
+		weights[k] = v
+	}
+	// If this isn't a profile we know and there are no overrides, fall back to the collector profile
+	if len(weights) == 0 && Config["pick_weights_"+profile] == "" {
+		for k, v := range defaultPickProfiles["collector"] {
+			weights[k] = v
+		}
+	}
+	for _, pair := range strings.Split(Config["pick_weights_"+profile], ",") {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		factor := strings.TrimSpace(kv[0])
+		w, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			fmt.Printf("Could not parse weight '%v' for '%v' in pick_weights_%v\n", kv[1], factor, profile)
+			continue
+		}
+		weights[factor] = w
+	}
+	return weights
+}
+
+// How many of a card make a playset
+func playsetSize() int {
+	n, err := strconv.Atoi(Config["playset_size"])
+	if err != nil || n < 1 {
+		return 4
+	}
+	return n
+}
+
+// Score every card in a pack and hand them back best first. Plat, gold and rating are scaled
+// against the best card in the pack so each factor runs from 0 to 1 before it's weighted.
+func scorePack(cards []Card, wheelPackNum int, profile string) []pickScore {
+	weights := pickWeights(profile)
+	var maxPlat, maxGold, maxRating float64
+	for _, c := range cards {
+		if float64(c.plat) > maxPlat {
+			maxPlat = float64(c.plat)
+		}
+		if float64(c.gold) > maxGold {
+			maxGold = float64(c.gold)
+		}
+		if cardRating(c) > maxRating {
+			maxRating = cardRating(c)
+		}
+	}
+	playset := playsetSize()
+	scores := make([]pickScore, 0, len(cards))
+	for _, c := range cards {
+		factors := make(map[string]float64)
+		if maxPlat > 0 {
+			factors["plat"] = float64(c.plat) / maxPlat
+		}
+		if maxGold > 0 {
+			factors["gold"] = float64(c.gold) / maxGold
+		}
+		if c.qty < playset {
+			factors["need"] = float64(playset-c.qty) / float64(playset)
+		}
+		// Cards that are likely to come back around can wait
+		factors["wheel"] = float64(100-wheelChance(c, wheelPackNum)) / 100
+		factors["rarity"] = rarityScores[c.rarity]
+		if maxRating > 0 {
+			factors["rating"] = cardRating(c) / maxRating
+		}
+		ps := pickScore{card: c, parts: make(map[string]float64)}
+		for _, f := range scoreFactors {
+			ps.parts[f] = factors[f] * weights[f]
+			ps.score += ps.parts[f]
+		}
+		scores = append(scores, ps)
+	}
+	// Ties go to plat and then gold, the same as the old comparisons did
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.card.plat != b.card.plat {
+			return a.card.plat > b.card.plat
+		}
+		return a.card.gold > b.card.gold
+	})
+	return scores
+}
+
+// Print out the top of the ranked list with each card's score broken down
+func sprintPickScores(scores []pickScore, profile string) string {
+	length, err := strconv.Atoi(Config["pick_list_length"])
+	if err != nil || length < 1 || length > len(scores) {
+		length = len(scores)
+	}
+	s := fmt.Sprintf("** Computed best picks from pack (%v profile):\n", profile)
+	for i, ps := range scores[:length] {
+		parts := make([]string, 0, len(scoreFactors))
+		for _, f := range scoreFactors {
+			if ps.parts[f] != 0 {
+				parts = append(parts, fmt.Sprintf("%v %.2f", f, ps.parts[f]))
+			}
+		}
+		s += fmt.Sprintf("\t%2d. %5.2f '%v' [%v]\n", i+1, ps.score, ps.card.name, strings.Join(parts, ", "))
+	}
+	return s
+}
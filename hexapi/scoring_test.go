@@ -0,0 +1,62 @@
+// Test cases for pick scoring
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScorePackProfiles(t *testing.T) {
+	Config = make(map[string]string)
+	cardRatings = map[string]float64{"Bomb": 5, "Filler": 1}
+	cards := []Card{
+		{name: "Pricey", uuid: "a", qty: 4, plat: 100, gold: 10000, rarity: "R"},
+		{name: "Need It", uuid: "b", qty: 0, plat: 1, gold: 100, rarity: "C"},
+		{name: "Bomb", uuid: "c", qty: 4, plat: 5, gold: 500, rarity: "U"},
+		{name: "Filler", uuid: "d", qty: 4, plat: 1, gold: 100, rarity: "C"},
+	}
+	for _, c := range []struct {
+		profile string
+		want    string
+	}{
+		{"profit", "Pricey"},
+		{"collector", "Need It"},
+		{"competitive", "Bomb"},
+		{"nonsense", "Need It"},
+	} {
+		scores := scorePack(cards, 0, c.profile)
+		if scores[0].card.name != c.want {
+			t.Errorf("scorePack() with the %v profile ranked '%v' first but we expected '%v'", c.profile, scores[0].card.name, c.want)
+		}
+		total := 0.0
+		for _, p := range scores[0].parts {
+			total += p
+		}
+		if math.Abs(total-scores[0].score) > 1e-9 {
+			t.Errorf("score breakdown for '%v' adds up to %v but the score is %v", scores[0].card.name, total, scores[0].score)
+		}
+	}
+}
+
+func TestPickWeightOverrides(t *testing.T) {
+	Config = make(map[string]string)
+	Config["pick_weights_profit"] = "need:7, rating:bogus"
+	Config["pick_weights_mine"] = "plat:2"
+	for _, c := range []struct {
+		profile string
+		factor  string
+		want    float64
+	}{
+		{"profit", "need", 7},
+		{"profit", "plat", 3},
+		{"profit", "rating", 0},
+		{"mine", "plat", 2},
+		{"mine", "need", 0},
+	} {
+		got := pickWeights(c.profile)[c.factor]
+		if got != c.want {
+			t.Errorf("pickWeights(%v)[%v] == %v but we expected %v", c.profile, c.factor, got, c.want)
+		}
+	}
+}
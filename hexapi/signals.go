@@ -0,0 +1,110 @@
+// Work out what the drafters upstream of us are taking from what goes missing out of wheeled packs
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shardSignal is how many cards of a shard came through our first-lap packs and how many of
+// those the other drafters took before the pack came back to us
+type shardSignal struct {
+	shard  string
+	seen   int
+	taken  int
+	byType map[string]int
+}
+
+// How much of this shard got taken
+func (s shardSignal) rate() float64 {
+	if s.seen == 0 {
+		return 0
+	}
+	return float64(s.taken) / float64(s.seen)
+}
+
+// Add up shard signals for a round from every pack that's come back around so far. Passing
+// direction changes between rounds, so each round has its own set of upstream neighbors.
+func (d *Draft) shardSignals(round int) map[string]*shardSignal {
+	signals := make(map[string]*shardSignal)
+	count := func(c DraftCard, taken bool) {
+		for _, shard := range splitShards(c.Shard) {
+			s, ok := signals[shard]
+			if !ok {
+				s = &shardSignal{shard: shard, byType: make(map[string]int)}
+				signals[shard] = s
+			}
+			s.seen++
+			if taken {
+				s.taken++
+				cardType := c.Type
+				if cardType == "" {
+					cardType = "Unknown"
+				}
+				s.byType[cardType]++
+			}
+		}
+	}
+	for _, pack := range d.Packs {
+		if pack.Round != round {
+			continue
+		}
+		for _, c := range pack.Wheeled {
+			count(c, false)
+		}
+		for _, c := range pack.Missing {
+			count(c, true)
+		}
+	}
+	return signals
+}
+
+// Shards the upstream drafters are leaving alone: the ones taken less often than average
+func openShards(signals map[string]*shardSignal) []string {
+	seen, taken := 0, 0
+	for shard, s := range signals {
+		if shard == "Unknown" {
+			continue
+		}
+		seen += s.seen
+		taken += s.taken
+	}
+	if seen == 0 {
+		return nil
+	}
+	average := float64(taken) / float64(seen)
+	var open []string
+	for _, shard := range shardNames {
+		if s, ok := signals[shard]; ok && s.rate() < average {
+			open = append(open, shard)
+		}
+	}
+	return open
+}
+
+// Print out what upstream is taking this round and which shards look open
+func (d *Draft) sprintShardSignals() string {
+	signals := d.shardSignals(d.Round)
+	if len(signals) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, shard := range append(shardNames, "Unknown") {
+		s, ok := signals[shard]
+		if !ok {
+			continue
+		}
+		part := fmt.Sprintf("%v taken %v/%v (%.0f%%)", shard, s.taken, s.seen, s.rate()*100)
+		if len(s.byType) > 0 {
+			part = fmt.Sprintf("%v [%v]", part, sprintCounts(s.byType))
+		}
+		parts = append(parts, part)
+	}
+	open := openShards(signals)
+	openString := "none yet"
+	if len(open) > 0 {
+		openString = strings.Join(open, ", ")
+	}
+	return fmt.Sprintf("-- UPSTREAM SIGNALS: %v\n-- OPEN SHARDS: %v\n", strings.Join(parts, "; "), openString)
+}
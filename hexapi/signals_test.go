@@ -0,0 +1,54 @@
+// Test cases for upstream shard signals
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShardSignals(t *testing.T) {
+	ruby := func(name string) DraftCard { return DraftCard{UUID: name, Name: name, Shard: "Ruby", Type: "Troop"} }
+	wild := func(name string) DraftCard { return DraftCard{UUID: name, Name: name, Shard: "Wild", Type: "Action"} }
+	d := &Draft{Round: 1, Packs: []DraftPack{
+		{Round: 1, Size: 9, Wheeled: []DraftCard{wild("w1"), wild("w2"), ruby("r1")}, Missing: []DraftCard{ruby("r2"), ruby("r3"), wild("w3")}},
+		{Round: 1, Size: 8, Wheeled: []DraftCard{wild("w4")}, Missing: []DraftCard{ruby("r4"), {UUID: "x", Name: "Mystery"}}},
+		// Other rounds have different neighbors and shouldn't count
+		{Round: 2, Size: 9, Missing: []DraftCard{wild("w5"), wild("w6")}},
+	}}
+	signals := d.shardSignals(1)
+	if s := signals["Ruby"]; s == nil || s.seen != 4 || s.taken != 3 || s.byType["Troop"] != 3 {
+		t.Errorf("Ruby signal is %+v but we expected 3 of 4 taken, all Troops", s)
+	}
+	if s := signals["Wild"]; s == nil || s.seen != 4 || s.taken != 1 {
+		t.Errorf("Wild signal is %+v but we expected 1 of 4 taken", s)
+	}
+	if s := signals["Unknown"]; s == nil || s.taken != 1 {
+		t.Errorf("Unknown signal is %+v but we expected 1 taken", s)
+	}
+	open := openShards(signals)
+	if len(open) != 1 || open[0] != "Wild" {
+		t.Errorf("openShards() == %v but we expected [Wild]", open)
+	}
+	if out := d.sprintShardSignals(); !strings.Contains(out, "OPEN SHARDS: Wild") {
+		t.Errorf("sprintShardSignals() == %q but we expected Wild to be open", out)
+	}
+}
+
+func TestSplitShards(t *testing.T) {
+	for _, c := range []struct {
+		given string
+		want  string
+	}{
+		{"Ruby", "Ruby"},
+		{"Ruby, Wild", "Ruby,Wild"},
+		{"wild/sapphire", "Sapphire,Wild"},
+		{"", "Unknown"},
+		{"Colorless", "Unknown"},
+	} {
+		got := strings.Join(splitShards(c.given), ",")
+		if got != c.want {
+			t.Errorf("splitShards(%q) == %v but we expected %v", c.given, got, c.want)
+		}
+	}
+}
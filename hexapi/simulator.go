@@ -0,0 +1,301 @@
+// Draft simulator: play recorded API messages back through the event handlers and compare what
+// comes out against a golden file
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Where the simulated clock starts and how far it moves for each message
+var simulationStart = time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+var simulationTick = time.Second
+
+// Play back recorded messages (one JSON message per line, the same as the API log file) and return
+// everything that was printed followed by the collection and profit state we ended up with.
+// Anything that would normally be written to disk goes to a scratch directory that's removed afterward.
+func simulateMessages(in io.Reader) (string, error) {
+	scratch, err := ioutil.TempDir("", "hexapi-simulation")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(scratch)
+
+	// Swap out the config, the clock and anything left over from a previous session. Whatever was
+	// there is put back afterward, so a simulation doesn't lose anything a live session has built up.
+	saved := saveSimulatedState()
+	defer saved.restore()
+	Config = make(map[string]string)
+	for k, v := range saved.config {
+		Config[k] = v
+	}
+	Config["draft_log_dir"] = scratch
+	Config["draft_ledger_file"] = scratch + "/draft_ledger.jsonl"
+	Config["collection_file"] = scratch + "/collection.out"
+	Config["card_db_file"] = scratch + "/carddb.txt"
+	Config["collection_audit_file"] = scratch + "/collection_audit.jsonl"
+	Config["match_history_file"] = scratch + "/match_history.jsonl"
+	Config["deck_library_file"] = scratch + "/deck_library.jsonl"
+	Config["export_csv"] = "false"
+	Config["log_api_calls"] = "false"
+	Config["upload_draft_data"] = "false"
+	Config["remote_name_lookup"] = "false"
+	// The simulation works on copies of the collection and what the audit trail knows of it so the
+	// messages can't change the real ones
+	cardCollection = make(map[string]Card, len(saved.collection))
+	for k, v := range saved.collection {
+		cardCollection[k] = v
+	}
+	auditKnown = make(map[string][2]int, len(saved.auditKnown))
+	for k, v := range saved.auditKnown {
+		auditKnown[k] = v
+	}
+	clock := simulationStart
+	now = func() time.Time { return clock }
+	sleep = func(time.Duration) {}
+	currentDraft = nil
+	currentlyDrafting = false
+	resetPickLedger()
+	overwriteBaseline = nil
+	updatesSinceOverwrite = make(map[string]int)
+	lastOverwriteReport = ""
+	sessionPlatProfit, sessionGoldProfit = 0, 0
+	lastAPIMessage = ""
+	wheelStats = make(map[string]*[18]wheelCount)
+	noteSavedDeck("", "")
+	gameMutex.Lock()
+	resetGame()
+	GameStartTime = clock
+	healthSamples = nil
+	gameStartSeen = false
+	resetTurns()
+	resetReveals()
+	gameMutex.Unlock()
+	readDeckLibrary()
+	lastLadderSeen, lastLadderType, lastTournamentSeen = time.Time{}, "", time.Time{}
+	defer func() {
+		if collectionCacheTimer != nil {
+			collectionCacheTimer.Stop()
+		}
+	}()
+
+	// Everything the handlers print goes to a file so we can hand it back
+	out, err := ioutil.TempFile(scratch, "output")
+	if err != nil {
+		return "", err
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		clock = clock.Add(simulationTick)
+		handleMessage([]byte(line))
+	}
+	os.Stdout = stdout
+	out.Close()
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	blob, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		return "", err
+	}
+	// The scratch directory is different every run, so take it out of the output
+	output := strings.Replace(string(blob), scratch, "<scratch>", -1)
+	return output + simulationState(), nil
+}
+
+// simulatedState is everything a simulation replaces, as it was before the simulation started
+type simulatedState struct {
+	config                                   map[string]string
+	collection                               map[string]Card
+	auditKnown                               map[string][2]int
+	deckLibrary                              map[string][]DeckRevision
+	deckValues                               map[string][2]int
+	now                                      func() time.Time
+	sleep                                    func(time.Duration)
+	currentDraft                             *Draft
+	currentlyDrafting                        bool
+	pendingPicks, matchedPicks               []pendingPick
+	pickDiscrepancies                        []pickDiscrepancy
+	matchedPickCount                         int
+	overwriteBaseline                        map[string][2]int
+	updatesSinceOverwrite                    map[string]int
+	lastOverwriteReport                      string
+	sessionPlatProfit, sessionGoldProfit     int
+	lastAPIMessage                           string
+	wheelStats                               map[string]*[18]wheelCount
+	savedDeckName, savedDeckChampion         string
+	currentGame                              Game
+	gameStartTime                            time.Time
+	healthSamples                            []HealthSample
+	gameStartSeen                            bool
+	turns                                    []*turnSummary
+	reveals                                  [2]map[string]string
+	lastLadderSeen, lastTournamentSeen       time.Time
+	lastLadderType                           string
+	collectionPlatValue, collectionGoldValue int
+}
+
+// Everything simulateMessages is about to replace. The simulation only ever swaps in new values
+// (the collection and audit counts are copied), so holding on to the old ones is enough to put them back.
+func saveSimulatedState() simulatedState {
+	s := simulatedState{
+		config: Config, collection: cardCollection, auditKnown: auditKnown, now: now, sleep: sleep,
+		currentDraft: currentDraft, currentlyDrafting: currentlyDrafting,
+		overwriteBaseline: overwriteBaseline, updatesSinceOverwrite: updatesSinceOverwrite, lastOverwriteReport: lastOverwriteReport,
+		sessionPlatProfit: sessionPlatProfit, sessionGoldProfit: sessionGoldProfit, lastAPIMessage: lastAPIMessage,
+		wheelStats: wheelStats, savedDeckName: savedDeckName, savedDeckChampion: savedDeckChampion,
+		lastLadderSeen: lastLadderSeen, lastTournamentSeen: lastTournamentSeen, lastLadderType: lastLadderType,
+		collectionPlatValue: collectionPlatValue, collectionGoldValue: collectionGoldValue,
+	}
+	pickLedgerMutex.Lock()
+	s.pendingPicks, s.matchedPicks, s.pickDiscrepancies, s.matchedPickCount = pendingPicks, matchedPicks, pickDiscrepancies, matchedPickCount
+	pickLedgerMutex.Unlock()
+	gameMutex.Lock()
+	s.currentGame, s.gameStartTime, s.healthSamples, s.gameStartSeen = currentGame, GameStartTime, healthSamples, gameStartSeen
+	s.turns, s.reveals = turns, reveals
+	gameMutex.Unlock()
+	deckLibraryMutex.Lock()
+	s.deckLibrary, s.deckValues = deckLibrary, deckValues
+	deckLibraryMutex.Unlock()
+	return s
+}
+
+func (s simulatedState) restore() {
+	Config, cardCollection, auditKnown, now, sleep = s.config, s.collection, s.auditKnown, s.now, s.sleep
+	currentDraft, currentlyDrafting = s.currentDraft, s.currentlyDrafting
+	overwriteBaseline, updatesSinceOverwrite, lastOverwriteReport = s.overwriteBaseline, s.updatesSinceOverwrite, s.lastOverwriteReport
+	sessionPlatProfit, sessionGoldProfit, lastAPIMessage = s.sessionPlatProfit, s.sessionGoldProfit, s.lastAPIMessage
+	wheelStats, savedDeckName, savedDeckChampion = s.wheelStats, s.savedDeckName, s.savedDeckChampion
+	lastLadderSeen, lastTournamentSeen, lastLadderType = s.lastLadderSeen, s.lastTournamentSeen, s.lastLadderType
+	collectionPlatValue, collectionGoldValue = s.collectionPlatValue, s.collectionGoldValue
+	pickLedgerMutex.Lock()
+	pendingPicks, matchedPicks, pickDiscrepancies, matchedPickCount = s.pendingPicks, s.matchedPicks, s.pickDiscrepancies, s.matchedPickCount
+	pickLedgerMutex.Unlock()
+	gameMutex.Lock()
+	currentGame, GameStartTime, healthSamples, gameStartSeen = s.currentGame, s.gameStartTime, s.healthSamples, s.gameStartSeen
+	turns, reveals = s.turns, s.reveals
+	gameMutex.Unlock()
+	deckLibraryMutex.Lock()
+	deckLibrary, deckValues = s.deckLibrary, s.deckValues
+	deckLibraryMutex.Unlock()
+}
+
+// The collection, pending draft picks and profit as they stand, in a stable order
+func simulationState() string {
+	s := "==========================       SIMULATION STATE       ==========================\n"
+	var cards []Card
+	for _, c := range cardCollection {
+		if c.qty != 0 || c.eaqty != 0 {
+			cards = append(cards, c)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool {
+		if cards[i].name != cards[j].name {
+			return cards[i].name < cards[j].name
+		}
+		return cards[i].uuid < cards[j].uuid
+	})
+	s += "Collection:\n"
+	for _, c := range cards {
+		s += fmt.Sprintf("\t%v %v (%v EA)\n", c.name, c.qty, c.eaqty)
+	}
+	var pending []string
+	pickLedgerMutex.Lock()
+	for _, p := range pendingPicks {
+		pending = append(pending, getCardNameFromUUID(p.uuid))
+	}
+	sort.Strings(pending)
+	s += fmt.Sprintf("Draft picks waiting on collection updates: %v\n", strings.Join(pending, ", "))
+	for _, d := range pickDiscrepancies {
+		s += fmt.Sprintf("Draft pick that didn't add up: %v\n", d)
+	}
+	pickLedgerMutex.Unlock()
+	s += fmt.Sprintf("Session profit: %vp (%vg)\n", sessionPlatProfit, sessionGoldProfit)
+	if d := currentDraft; d != nil {
+		plat, gold := d.poolValue()
+		s += fmt.Sprintf("Draft %v: round %v of %v, %v picks, finished %v, pool value %vp (%vg)\n", d.ID, d.Round, d.Rounds, len(d.Picks), d.Finished, plat, gold)
+	}
+	return s
+}
+
+// Compare simulation output with a golden file. With 'update' set, the golden file is rewritten instead.
+func compareGolden(got string, goldenFile string, update bool) error {
+	if update {
+		return ioutil.WriteFile(goldenFile, []byte(got), 0660)
+	}
+	blob, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		return err
+	}
+	want := string(blob)
+	if got == want {
+		return nil
+	}
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		g, w := "<end of output>", "<end of output>"
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Errorf("output differs from %v at line %v:\n\tgot:  %v\n\twant: %v", goldenFile, i+1, g, w)
+		}
+	}
+	return fmt.Errorf("output differs from %v", goldenFile)
+}
+
+// Run a recording through the simulator, then print the output or check it against a golden file
+func runSimulation(args []string) int {
+	update := false
+	if len(args) > 0 && args[0] == "-update" {
+		update = true
+		args = args[1:]
+	}
+	if len(args) == 0 || (update && len(args) < 2) {
+		fmt.Println("Usage: simulate [-update] <recorded messages> [golden file]")
+		return 1
+	}
+	in, err := os.Open(args[0])
+	if err != nil {
+		fmt.Printf("Could not open recorded messages %v: %v\n", args[0], err)
+		return 1
+	}
+	defer in.Close()
+	got, err := simulateMessages(in)
+	if err != nil {
+		fmt.Printf("Could not simulate %v: %v\n", args[0], err)
+		return 1
+	}
+	if len(args) < 2 {
+		fmt.Print(got)
+		return 0
+	}
+	if err := compareGolden(got, args[1], update); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if update {
+		fmt.Printf("Wrote %v\n", args[1])
+	} else {
+		fmt.Printf("Output matches %v\n", args[1])
+	}
+	return 0
+}
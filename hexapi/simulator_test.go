@@ -0,0 +1,63 @@
+// Test cases for the draft simulator
+
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the simulator golden files")
+
+func TestDraftSimulation(t *testing.T) {
+	testDraftSetup(t, 136)
+	Config = loadDefaults()
+	Config["draft_rounds"] = "1"
+	packCost, packGoldCost = 100, 10000
+	for uuid, c := range cardCollection {
+		c.qty, c.eaqty = 0, 0
+		cardCollection[uuid] = c
+	}
+	before := make(map[string]Card)
+	for uuid, c := range cardCollection {
+		before[uuid] = c
+	}
+	deckLibrary = map[string][]DeckRevision{"Yetis": {{Name: "Yetis", Revision: 1}}}
+	deckValues = map[string][2]int{"Yetis": {1, 100}}
+	// A live session's draft, profit, wheel stats and saved deck have to survive a simulation too
+	liveDraft := &Draft{ID: "live"}
+	currentDraft, currentlyDrafting = liveDraft, true
+	sessionPlatProfit, sessionGoldProfit = 12, 1200
+	wheelStats = map[string]*[18]wheelCount{"live": {}}
+	noteSavedDeck("Yetis", "Uzume")
+	in, err := os.Open("testdata/draft_round.log")
+	if err != nil {
+		t.Fatalf("Could not open recording: %v", err)
+	}
+	defer in.Close()
+	got, err := simulateMessages(in)
+	if err != nil {
+		t.Fatalf("simulateMessages() failed: %v", err)
+	}
+	if err := compareGolden(got, "testdata/draft_round.golden", *updateGolden); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(cardCollection, before) {
+		t.Errorf("simulateMessages() left changes in the collection")
+	}
+	if len(deckLibrary["Yetis"]) != 1 || deckValues["Yetis"] != [2]int{1, 100} {
+		t.Errorf("simulateMessages() didn't put the deck library back: %v %v", deckLibrary, deckValues)
+	}
+	if currentDraft != liveDraft || !currentlyDrafting || sessionPlatProfit != 12 || sessionGoldProfit != 1200 {
+		t.Errorf("simulateMessages() didn't put the session back: draft %+v drafting %v profit %vp (%vg)", currentDraft, currentlyDrafting, sessionPlatProfit, sessionGoldProfit)
+	}
+	if _, ok := wheelStats["live"]; !ok || len(wheelStats) != 1 || savedDeckName != "Yetis" || savedDeckChampion != "Uzume" {
+		t.Errorf("simulateMessages() didn't put the wheel stats or saved deck back: %v %v %v", wheelStats, savedDeckName, savedDeckChampion)
+	}
+	currentDraft, currentlyDrafting = nil, false
+	sessionPlatProfit, sessionGoldProfit = 0, 0
+	wheelStats = make(map[string]*[18]wheelCount)
+	noteSavedDeck("", "")
+}
@@ -0,0 +1,136 @@
+// Win-rate statistics from the match history, by champion, opponent, deck, month and format
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// matchTotals adds up a group of games. Games where we can't tell which side was ours count
+// towards the number of games and their length, but not the win rate. Games we didn't see the start
+// of ('timed' doesn't count them) are left out of the lengths.
+type matchTotals struct {
+	games   int
+	wins    int
+	losses  int
+	timed   int
+	seconds int
+	long    int
+}
+
+// How long a game has to go (in seconds) before it counts as a long one
+func longGameSeconds() int {
+	minutes, err := strconv.Atoi(Config["long_game_minutes"])
+	if err != nil {
+		minutes = 20
+	}
+	return minutes * 60
+}
+
+func (t *matchTotals) add(m MatchRecord) {
+	t.games++
+	switch m.Result {
+	case "won":
+		t.wins++
+	case "lost":
+		t.losses++
+	}
+	if m.Partial {
+		return
+	}
+	t.timed++
+	t.seconds += m.Seconds
+	if m.Seconds >= longGameSeconds() {
+		t.long++
+	}
+}
+
+// Wins as a percentage of the games we know the result of
+func (t matchTotals) winRate() float64 {
+	if t.wins+t.losses == 0 {
+		return 0
+	}
+	return float64(t.wins) * 100 / float64(t.wins+t.losses)
+}
+
+func (t matchTotals) String() string {
+	average := 0
+	if t.timed > 0 {
+		average = t.seconds / t.timed
+	}
+	return fmt.Sprintf("%3d games %3d-%-3d win rate %5.1f%% | average %8v | %3d long", t.games, t.wins, t.losses, t.winRate(), sprintDuration(average), t.long)
+}
+
+// Totals for the games grouped by whatever 'key' pulls out of each one, sorted by group name.
+// Games the key returns "" for are left out.
+func groupMatches(matches []MatchRecord, key func(MatchRecord) string) ([]string, map[string]*matchTotals) {
+	groups := make(map[string]*matchTotals)
+	var names []string
+	for _, m := range matches {
+		k := key(m)
+		if k == "" {
+			continue
+		}
+		if _, ok := groups[k]; !ok {
+			groups[k] = &matchTotals{}
+			names = append(names, k)
+		}
+		groups[k].add(m)
+	}
+	sort.Strings(names)
+	return names, groups
+}
+
+// Overall stats, then by our champion, opponent champion, deck, month and format. The filters
+// are the same ones games takes, so "days=30" gives us the last month.
+func matchStatsReport(filters map[string]string) string {
+	matches, err := filterMatches(readMatches(), filters)
+	if err != nil {
+		return fmt.Sprintf("%v\n", err)
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("No games recorded in '%v' match %v\n", Config["match_history_file"], filters)
+	}
+	var overall matchTotals
+	for _, m := range matches {
+		overall.add(m)
+	}
+	s := "==========================         MATCH STATISTICS         ==========================\n"
+	s += fmt.Sprintf("Overall: %v (long is %v minutes or more)\n", overall, longGameSeconds()/60)
+	for _, g := range []struct {
+		title string
+		key   func(MatchRecord) string
+	}{
+		{"By our champion", func(m MatchRecord) string { ours, _ := m.sides(); return ours }},
+		{"By opponent champion", func(m MatchRecord) string { _, theirs := m.sides(); return theirs }},
+		{"By deck", func(m MatchRecord) string { return m.Deck }},
+		{"By month", func(m MatchRecord) string { return m.Started.Format("2006-01") }},
+		{"By format", func(m MatchRecord) string {
+			if m.LadderType != "" {
+				return m.Format + " " + m.LadderType
+			}
+			return m.Format
+		}},
+	} {
+		names, groups := groupMatches(matches, g.key)
+		if len(names) == 0 {
+			continue
+		}
+		s += g.title + ":\n"
+		for _, n := range names {
+			s += fmt.Sprintf("\t%-30v %v\n", n, groups[n])
+		}
+	}
+	return s
+}
+
+func statsRequest(rw http.ResponseWriter, req *http.Request) {
+	filters := queryFilters(req)
+	fmt.Printf("Request for match statistics for %v received.\n", filters)
+	report := matchStatsReport(filters)
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
@@ -0,0 +1,65 @@
+// Test cases for match statistics
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchStats(t *testing.T) {
+	Config = make(map[string]string)
+	Config["match_history_file"] = filepath.Join(t.TempDir(), "match_history.jsonl")
+	Config["long_game_minutes"] = "10"
+	clock := time.Date(2017, time.March, 10, 12, 0, 0, 0, time.UTC)
+	savedNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = savedNow }()
+
+	var history []byte
+	for _, m := range []MatchRecord{
+		{Started: clock.AddDate(0, -1, 0), Seconds: 300, Format: "casual", Deck: "Uzume Aggro", Winner: "Uzume", Loser: "Fuzzuko", Result: "won"},
+		{Started: clock.AddDate(0, 0, -1), Seconds: 900, Format: "ladder", LadderType: "Constructed", Deck: "Uzume Aggro", Winner: "Zared", Loser: "Uzume", Result: "lost"},
+		{Started: clock.AddDate(0, 0, -2), Seconds: 600, Format: "ladder", LadderType: "Constructed", Deck: "Uzume Aggro", Winner: "Uzume", Loser: "Zared", Result: "won"},
+		{Started: clock.AddDate(0, 0, -3), Seconds: 60, Format: "casual", Winner: "Zared", Loser: "Fuzzuko", Result: "unknown"},
+	} {
+		blob, _ := json.Marshal(m)
+		history = append(append(history, blob...), '\n')
+	}
+	if err := ioutil.WriteFile(Config["match_history_file"], history, 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	var totals matchTotals
+	for _, m := range readMatches() {
+		totals.add(m)
+	}
+	if totals.games != 4 || totals.wins != 2 || totals.losses != 1 || totals.long != 2 {
+		t.Errorf("totals == %+v but we expected 4 games, 2 wins, 1 loss and 2 long", totals)
+	}
+	if rate := totals.winRate(); rate < 66.6 || rate > 66.7 {
+		t.Errorf("winRate() == %v but we expected 66.7", rate)
+	}
+
+	report := matchStatsReport(map[string]string{})
+	for _, want := range []string{
+		"Overall:   4 games   2-1   win rate  66.7% | average   7m 45s |   2 long",
+		"\tUzume                            3 games   2-1   win rate  66.7%",
+		"\tZared                            2 games   1-1   win rate  50.0%",
+		"\t2017-02 ",
+		"\tladder Constructed               2 games   1-1   win rate  50.0% | average  12m 30s |   2 long",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("stats report does not contain %q:\n%v", want, report)
+		}
+	}
+	// The last week leaves out the game from last month
+	report = matchStatsReport(map[string]string{"days": "7"})
+	if !strings.Contains(report, "Overall:   3 games   1-1 ") || strings.Contains(report, "2017-02") {
+		t.Errorf("stats report for the last week is:\n%v\nbut we expected 3 games and nothing from February", report)
+	}
+}
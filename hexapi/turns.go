@@ -0,0 +1,161 @@
+// Turns: work out whose turn it is from the messages we get and sum each turn up in one line
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// turnSummary is what happened in one turn. Everything is indexed by player, 0 for p1 and 1 for p2.
+type turnSummary struct {
+	number    int
+	active    int
+	resources int
+	played    [2][]string
+	shards    [2][]string
+	damage    [2]int
+}
+
+// The "started the turn on your side" bit, looked up once in the Card States table at the top of hexapi.go
+var startedTurnState, _ = cardStateFlags.encode([]string{"started the turn on your side"})
+
+// Every turn of the game in progress, the one being played last. Guarded by gameMutex.
+var turns []*turnSummary
+
+func resetTurns() {
+	turns = nil
+}
+
+// Whether to print a summary for each turn instead of a line for every card and resource change
+func turnSummaries() bool {
+	return Config["turn_summaries"] == "true"
+}
+
+func playerIndex(p *Player) int {
+	if p == &currentGame.p2 {
+		return 1
+	}
+	return 0
+}
+
+// What we call a player in the summaries: their champion if we know it
+func playerLabel(i int) string {
+	p := &currentGame.p1
+	if i == 1 {
+		p = &currentGame.p2
+	}
+	if p.champion.name != "" && p.champion.name != "Unknown" {
+		return p.champion.name
+	}
+	return p.name
+}
+
+func currentTurn() *turnSummary {
+	if len(turns) == 0 {
+		return nil
+	}
+	return turns[len(turns)-1]
+}
+
+// Hex never tells us when a turn starts, so we infer it. A player's turn has started when they
+// play a shard, when their resources go up (they ready at the start of the turn), or when one of
+// their cards picks up the "started the turn on your side" state, and it wasn't already their turn.
+func startTurn(p *Player) {
+	i := playerIndex(p)
+	if t := currentTurn(); t != nil && t.active == i {
+		return
+	}
+	endTurn()
+	turns = append(turns, &turnSummary{number: len(turns) + 1, active: i, resources: p.resources})
+}
+
+// Print the summary for the turn that's just finished
+func endTurn() {
+	if t := currentTurn(); t != nil && turnSummaries() {
+		fmt.Println(t.String())
+	}
+}
+
+// A card changed. 'old' is what it looked like before, if 'seen'.
+func noteTurnCard(p *Player, old gameCard, seen bool, gc gameCard) {
+	if gc.state&startedTurnState != 0 && (!seen || old.state&startedTurnState == 0) {
+		startTurn(p)
+	}
+	if seen && old.location == gc.location {
+		return
+	}
+	i := playerIndex(p)
+	switch {
+	case gc.location&64 != 0:
+		startTurn(p)
+		currentTurn().shards[i] = append(currentTurn().shards[i], gc.name)
+	case gc.location&128 != 0:
+		// Nothing has told us a turn started yet, so whoever plays first is taking the first turn
+		if currentTurn() == nil {
+			startTurn(p)
+		}
+		currentTurn().played[i] = append(currentTurn().played[i], gc.name)
+	}
+}
+
+// A player's resources changed
+func noteTurnResources(p *Player, before int) {
+	if p.resources > before {
+		startTurn(p)
+	}
+	if t := currentTurn(); t != nil && t.active == playerIndex(p) && p.resources > t.resources {
+		t.resources = p.resources
+	}
+}
+
+// A player's champion lost health
+func noteTurnDamage(p *Player, damage int) {
+	if t := currentTurn(); t != nil {
+		t.damage[playerIndex(p)] += damage
+	}
+}
+
+// Something like "TURN 3 (Uzume, 5 resources): shards Ruby Shard | Uzume played Yeti Spy | Fuzzuko took 2 damage"
+func (t *turnSummary) String() string {
+	parts := []string{}
+	if len(t.shards[t.active]) > 0 {
+		parts = append(parts, "shards "+strings.Join(t.shards[t.active], ", "))
+	}
+	for _, i := range []int{t.active, 1 - t.active} {
+		if len(t.played[i]) > 0 {
+			parts = append(parts, fmt.Sprintf("%v played %v", playerLabel(i), strings.Join(t.played[i], ", ")))
+		}
+	}
+	for _, i := range []int{1 - t.active, t.active} {
+		if t.damage[i] > 0 {
+			parts = append(parts, fmt.Sprintf("%v took %v damage", playerLabel(i), t.damage[i]))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "nothing played")
+	}
+	return fmt.Sprintf("TURN %v (%v, %v resources): %v", t.number, playerLabel(t.active), t.resources, strings.Join(parts, " | "))
+}
+
+// Every turn so far in the game in progress
+func turnsReport() string {
+	if len(turns) == 0 {
+		return "No turns played yet\n"
+	}
+	s := ""
+	for _, t := range turns {
+		s += t.String() + "\n"
+	}
+	return s
+}
+
+func turnsRequest(rw http.ResponseWriter, req *http.Request) {
+	fmt.Println("Request to print turn summaries received.")
+	gameMutex.Lock()
+	report := turnsReport()
+	gameMutex.Unlock()
+	fmt.Print(report)
+	rw.Write([]byte(report))
+}
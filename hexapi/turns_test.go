@@ -0,0 +1,91 @@
+// Test cases for turn tracking
+
+package main
+
+import (
+	"testing"
+)
+
+func testPlayerUpdatedMessage(id int, resources int) map[string]interface{} {
+	return map[string]interface{}{
+		"Message": "PlayerUpdated", "Id": float64(id), "Resources": float64(resources),
+		"Thresholds": map[string]interface{}{"Blood": float64(0), "Diamond": float64(0), "Ruby": float64(resources), "Sapphire": float64(0), "Wild": float64(0)},
+	}
+}
+
+func TestTurnTracking(t *testing.T) {
+	Config = make(map[string]string)
+	Config["turn_summaries"] = "true"
+	resetGame()
+	resetTurns()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+
+	// Turn 1: p1 plays a shard, gets a resource and plays a troop. p2 answers with a quick action.
+	cardUpdatedEvent(testCardUpdatedMessage(10, "Ruby Shard", 1, 64, 0, 0))
+	playerUpdatedEvent(testPlayerUpdatedMessage(1, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(11, "Yeti Spy", 1, 128, 2, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(11, "Yeti Spy", 1, 8, 2, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(20, "Burn", 2, 128, 0, 0))
+	// Turn 2: p2's resources go up when they ready
+	playerUpdatedEvent(testPlayerUpdatedMessage(2, 1))
+	cardUpdatedEvent(testCardUpdatedMessage(21, "Wild Shard", 2, 64, 0, 0))
+	// Turn 3: the Yeti hits Fuzzuko
+	cardUpdatedEvent(testCardUpdatedMessage(12, "Ruby Shard", 1, 64, 0, 0))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 18))
+
+	if len(turns) != 3 {
+		t.Fatalf("we tracked %v turns but we expected 3:\n%v", len(turns), turnsReport())
+	}
+	for i, want := range []string{
+		"TURN 1 (Uzume, 1 resources): shards Ruby Shard | Uzume played Yeti Spy | Fuzzuko played Burn",
+		"TURN 2 (Fuzzuko, 1 resources): shards Wild Shard",
+		"TURN 3 (Uzume, 1 resources): shards Ruby Shard | Fuzzuko took 2 damage",
+	} {
+		if got := turns[i].String(); got != want {
+			t.Errorf("turn %v == %q but we expected %q", i+1, got, want)
+		}
+	}
+}
+
+func TestTurnTrackingCombinedZones(t *testing.T) {
+	Config = make(map[string]string)
+	Config["turn_summaries"] = "true"
+	resetGame()
+	resetTurns()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+	// Zones can have other bits set along with the one we care about
+	cardUpdatedEvent(testCardUpdatedMessage(10, "Ruby Shard", 1, 64|1024, 0, 0))
+	cardUpdatedEvent(testCardUpdatedMessage(11, "Burn", 1, 128|512, 0, 0))
+
+	if len(turns) != 1 {
+		t.Fatalf("we tracked %v turns but we expected 1:\n%v", len(turns), turnsReport())
+	}
+	if got, want := turns[0].String(), "TURN 1 (Uzume, 0 resources): shards Ruby Shard | Uzume played Burn"; got != want {
+		t.Errorf("turn 1 == %q but we expected %q", got, want)
+	}
+}
+
+func TestTurnTrackingChainFirst(t *testing.T) {
+	Config = make(map[string]string)
+	Config["turn_summaries"] = "true"
+	resetGame()
+	resetTurns()
+	cardUpdatedEvent(testCardUpdatedMessage(1, "Uzume", 1, 4, 0, 20))
+	cardUpdatedEvent(testCardUpdatedMessage(2, "Fuzzuko", 2, 4, 0, 20))
+	// A card on the chain before any shard or resource change still counts for the first turn
+	cardUpdatedEvent(testCardUpdatedMessage(11, "Burn", 1, 128, 0, 0))
+	cardUpdatedEvent(testCardUpdatedMessage(10, "Ruby Shard", 1, 64, 0, 0))
+
+	if len(turns) != 1 {
+		t.Fatalf("we tracked %v turns but we expected 1:\n%v", len(turns), turnsReport())
+	}
+	if got, want := turns[0].String(), "TURN 1 (Uzume, 0 resources): shards Ruby Shard | Uzume played Burn"; got != want {
+		t.Errorf("turn 1 == %q but we expected %q", got, want)
+	}
+	if names := cardStateFlags.decode(startedTurnState); len(names) != 1 || names[0] != "started the turn on your side" {
+		t.Errorf("startedTurnState is %v (%v) but we expected the one bit for \"started the turn on your side\"", startedTurnState, names)
+	}
+}
@@ -0,0 +1,168 @@
+// Send the packs we see and the picks we make to 'post_draft_data_url' (if we've opted in)
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// draftUpload is a pack and the pick we made from it, as we send it to the draft data URL.
+// This is built from card UUIDs only so account names never leave the machine.
+type draftUpload struct {
+	DraftID string    `json:"draft_id"`
+	Format  string    `json:"format"`
+	Round   int       `json:"round"`
+	Size    int       `json:"size"`
+	Cards   []string  `json:"cards"`
+	Pick    string    `json:"pick"`
+	Seen    time.Time `json:"seen"`
+}
+
+// Uploads waiting to go out. Saved to 'upload_queue_file' so we don't lose them on a restart.
+var uploadQueue []draftUpload
+
+// Send whatever's queued up a minute after the last pick. Failed sends back off from there.
+var uploadTimerPeriod = time.Minute * time.Duration(1)
+var uploadTimer *time.Timer
+var uploadFailures int
+
+// uploadMutex guards the queue, the timer and the failure count. Events come in on the API
+// server's goroutines and sends happen on the timer's, so everything that touches them locks.
+// uploadFlushMutex makes sure only one flush is sending at a time.
+var uploadMutex sync.Mutex
+var uploadFlushMutex sync.Mutex
+
+// Queue up the pack we picked from (if the user has opted in to sharing draft data)
+func queueDraftUpload(d *Draft, pick DraftPick) {
+	if Config["upload_draft_data"] != "true" {
+		return
+	}
+	up := draftUpload{DraftID: d.ID, Format: d.Format, Round: pick.Round, Size: pick.Size, Pick: pick.Card.UUID, Seen: pick.Picked}
+	if pack := d.packFor(pick.Round, pick.Size); pack != nil {
+		up.Seen = pack.Seen
+		for _, c := range pack.Cards {
+			up.Cards = append(up.Cards, c.UUID)
+		}
+	}
+	uploadMutex.Lock()
+	defer uploadMutex.Unlock()
+	uploadQueue = append(uploadQueue, up)
+	saveUploadQueue()
+	// A full batch goes out straight away, but never from here: sending can take a while with
+	// retries and we don't want to hold up the message handler
+	if len(uploadQueue) >= uploadBatchSize() {
+		scheduleDraftUploads(0)
+		return
+	}
+	scheduleDraftUploads(uploadTimerPeriod)
+}
+
+// How many uploads we send in one request
+func uploadBatchSize() int {
+	n, err := strconv.Atoi(Config["upload_batch_size"])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// The caller holds uploadMutex
+func scheduleDraftUploads(wait time.Duration) {
+	if uploadTimer != nil {
+		uploadTimer.Stop()
+	}
+	uploadTimer = time.AfterFunc(wait, flushDraftUploads)
+}
+
+// Send everything in the queue, a batch at a time. If a batch fails, keep it (and everything
+// after it) and try again later, waiting twice as long each time it keeps failing.
+// This runs on the timer's goroutine. The queue isn't locked while a batch is being sent, so picks
+// can keep coming in; they only ever go on the end, and only a flush takes anything off the front.
+func flushDraftUploads() {
+	uploadFlushMutex.Lock()
+	defer uploadFlushMutex.Unlock()
+	uploadMutex.Lock()
+	defer uploadMutex.Unlock()
+	if uploadTimer != nil {
+		uploadTimer.Stop()
+	}
+	if len(uploadQueue) == 0 {
+		return
+	}
+	batchSize := uploadBatchSize()
+	for len(uploadQueue) > 0 {
+		end := batchSize
+		if end > len(uploadQueue) {
+			end = len(uploadQueue)
+		}
+		batch := append([]draftUpload(nil), uploadQueue[:end]...)
+		uploadMutex.Unlock()
+		err := sendDraftUploads(batch)
+		uploadMutex.Lock()
+		if err != nil {
+			uploadFailures++
+			wait := uploadTimerPeriod
+			for i := 1; i < uploadFailures && wait < time.Hour; i++ {
+				wait *= 2
+			}
+			fmt.Printf("Could not send draft data to %v: %v. Will try again in %v.\n", Config["post_draft_data_url"], err, wait)
+			saveUploadQueue()
+			scheduleDraftUploads(wait)
+			return
+		}
+		uploadFailures = 0
+		uploadQueue = uploadQueue[end:]
+	}
+	saveUploadQueue()
+}
+
+// POST a batch of uploads as a JSON array
+func sendDraftUploads(batch []draftUpload) error {
+	blob, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	Debug(Config["debug_uploads"], "[sendDraftUploads] Sending %v packs to %v", len(batch), Config["post_draft_data_url"])
+	_, err = sharedFetcher().post(context.Background(), Config["post_draft_data_url"], "application/json", blob)
+	return err
+}
+
+// Save whatever hasn't gone out yet. The caller holds uploadMutex.
+func saveUploadQueue() {
+	queueFile := Config["upload_queue_file"]
+	if queueFile == "" {
+		return
+	}
+	blob, err := json.Marshal(uploadQueue)
+	if err != nil {
+		fmt.Printf("Could not encode upload queue: %v\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(queueFile, blob, 0660); err != nil {
+		fmt.Printf("Could not write upload queue %v: %v\n", queueFile, err)
+	}
+}
+
+// Read in whatever didn't go out last time and try sending it again
+func readUploadQueue() {
+	uploadMutex.Lock()
+	defer uploadMutex.Unlock()
+	blob, err := ioutil.ReadFile(Config["upload_queue_file"])
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(blob, &uploadQueue); err != nil {
+		fmt.Printf("Could not read upload queue %v: %v\n", Config["upload_queue_file"], err)
+		return
+	}
+	if len(uploadQueue) > 0 && Config["upload_draft_data"] == "true" {
+		fmt.Printf("Found %v packs of draft data that haven't been sent yet\n", len(uploadQueue))
+		scheduleDraftUploads(uploadTimerPeriod)
+	}
+}
@@ -0,0 +1,125 @@
+// Test cases for the draft data uploader
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A stand-in for the draft data receiver. Hands back everything it was sent. Sends happen on the
+// upload timer's goroutine, so wait for them with testWaitForUploads before looking.
+func testDraftReceiver(t *testing.T, status int) (*httptest.Server, *[][]draftUpload, *[]string) {
+	var batches [][]draftUpload
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		uploadMutex.Lock()
+		defer uploadMutex.Unlock()
+		bodies = append(bodies, string(body))
+		if status != http.StatusOK {
+			rw.WriteHeader(status)
+			return
+		}
+		var batch []draftUpload
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("receiver could not decode %q: %v", body, err)
+		}
+		batches = append(batches, batch)
+	}))
+	return ts, &batches, &bodies
+}
+
+func testUploaderSetup(t *testing.T, url string) {
+	uuids := testDraftSetup(t, 17)
+	Config["upload_draft_data"] = "true"
+	Config["upload_batch_size"] = "2"
+	Config["upload_queue_file"] = filepath.Join(t.TempDir(), "upload_queue.json")
+	Config["post_draft_data_url"] = url
+	Config["http_retries"] = "0"
+	httpFetcher = newFetcher()
+	uploadQueue = nil
+	uploadFailures = 0
+	// The User field is where the account name lives and it must never be sent on
+	pack := testDraftPackMessage(uuids)
+	pack["User"] = "SecretAccountName"
+	draftPackEvent(pack)
+	draftCardPickedEvent(testDraftPickMessage(uuids[0]))
+	pack = testDraftPackMessage(uuids[1:])
+	pack["User"] = "SecretAccountName"
+	draftPackEvent(pack)
+	draftCardPickedEvent(testDraftPickMessage(uuids[1]))
+}
+
+// Wait until the receiver has had 'n' requests and the flush that sent them is done, then stop
+// any retry it scheduled
+func testWaitForUploads(t *testing.T, bodies *[]string, n int) {
+	for start := time.Now(); ; time.Sleep(time.Millisecond * 10) {
+		uploadMutex.Lock()
+		got := len(*bodies)
+		uploadMutex.Unlock()
+		if got >= n {
+			break
+		}
+		if time.Since(start) > time.Second*5 {
+			t.Fatalf("receiver got %v requests but we expected %v", got, n)
+		}
+	}
+	uploadFlushMutex.Lock()
+	defer uploadFlushMutex.Unlock()
+	uploadMutex.Lock()
+	defer uploadMutex.Unlock()
+	if uploadTimer != nil {
+		uploadTimer.Stop()
+	}
+}
+
+func TestDraftUploads(t *testing.T) {
+	ts, batches, bodies := testDraftReceiver(t, http.StatusOK)
+	defer ts.Close()
+	testUploaderSetup(t, ts.URL)
+	testWaitForUploads(t, bodies, 1)
+
+	if len(*batches) != 1 || len((*batches)[0]) != 2 {
+		t.Fatalf("receiver got %v but we expected one batch of two packs", *batches)
+	}
+	first := (*batches)[0][0]
+	if first.Size != 17 || len(first.Cards) != 17 || first.Pick == "" {
+		t.Errorf("first upload was %+v but we expected the 17 card pack and our pick", first)
+	}
+	for _, body := range *bodies {
+		if strings.Contains(body, "SecretAccountName") {
+			t.Errorf("account name was sent to the draft data URL: %v", body)
+		}
+	}
+	if len(uploadQueue) != 0 {
+		t.Errorf("upload queue has %v entries after a good send but we expected 0", len(uploadQueue))
+	}
+}
+
+func TestDraftUploadsFailure(t *testing.T) {
+	ts, _, bodies := testDraftReceiver(t, http.StatusInternalServerError)
+	defer ts.Close()
+	testUploaderSetup(t, ts.URL)
+	testWaitForUploads(t, bodies, 1)
+
+	if len(*bodies) != 1 {
+		t.Errorf("receiver got %v requests but we expected 1", len(*bodies))
+	}
+	if len(uploadQueue) != 2 {
+		t.Fatalf("upload queue has %v entries after a failed send but we expected 2", len(uploadQueue))
+	}
+	// What didn't go out should have been saved so we can send it after a restart
+	uploadQueue = nil
+	readUploadQueue()
+	uploadTimer.Stop()
+	if len(uploadQueue) != 2 {
+		t.Errorf("read %v entries back from the upload queue file but we expected 2", len(uploadQueue))
+	}
+}
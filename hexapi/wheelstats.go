@@ -0,0 +1,139 @@
+// Wheel chances learned from our own drafts, to go along with (or instead of) the downloaded ones
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// wheelCount is how many times we've passed a card at a pick and how many times it came back
+type wheelCount struct {
+	seen    int
+	wheeled int
+}
+
+// Our own wheel counts, by card UUID and then by the same pick index 'wiw' uses
+var wheelStats = make(map[string]*[18]wheelCount)
+
+// Count up every pack that came back around in these drafts
+func learnWheelStats(drafts []*Draft) {
+	for _, d := range drafts {
+		for _, pack := range d.Packs {
+			if first := d.packFor(pack.Round, pack.Size+8); first != nil {
+				recordWheels(d, *first, pack)
+			}
+		}
+	}
+}
+
+// Record which of the cards we passed in 'first' were still there when it came back as 'back'.
+// Whatever we took on the first lap never had a chance to wheel, so it isn't counted.
+func recordWheels(d *Draft, first DraftPack, back DraftPack) {
+	place := packToWheelNumber(first.Size)
+	if place == 0 {
+		return
+	}
+	ours := ""
+	if p := d.pickFor(first.Round, first.Size); p != nil {
+		ours = p.Card.UUID
+	}
+	wheeled := make(map[string]int)
+	for _, c := range back.Wheeled {
+		wheeled[c.UUID]++
+	}
+	for _, c := range first.Cards {
+		if c.UUID == ours {
+			ours = ""
+			continue
+		}
+		counts, ok := wheelStats[c.UUID]
+		if !ok {
+			counts = &[18]wheelCount{}
+			wheelStats[c.UUID] = counts
+		}
+		counts[place].seen++
+		if wheeled[c.UUID] > 0 {
+			wheeled[c.UUID]--
+			counts[place].wheeled++
+		}
+	}
+}
+
+// How often a card has wheeled from this pick in our own drafts
+func localWheelCount(uuid string, place int) wheelCount {
+	if counts, ok := wheelStats[uuid]; ok {
+		return counts[place]
+	}
+	return wheelCount{}
+}
+
+// The chance a card comes back to us from this pick. With 'wheel_stats' set to "blend" the
+// downloaded figure counts as 'wheel_stats_weight' drafts' worth of our own observations, so
+// our numbers take over as we see more of them. "local" and "downloaded" use only one or the other.
+func wheelChance(c Card, place int) int {
+	local := localWheelCount(c.uuid, place)
+	switch Config["wheel_stats"] {
+	case "downloaded":
+		return c.wiw[place]
+	case "local":
+		if local.seen == 0 {
+			return c.wiw[place]
+		}
+		return local.wheeled * 100 / local.seen
+	}
+	if local.seen == 0 {
+		return c.wiw[place]
+	}
+	weight, err := strconv.Atoi(Config["wheel_stats_weight"])
+	if err != nil || weight < 0 {
+		weight = 10
+	}
+	return (c.wiw[place]*weight + local.wheeled*100) / (weight + local.seen)
+}
+
+// The wheel chance along with how many times we've seen it happen ourselves
+func sprintWheelChance(c Card, place int) string {
+	local := localWheelCount(c.uuid, place)
+	if local.seen == 0 {
+		return fmt.Sprintf("%3d%%", wheelChance(c, place))
+	}
+	return fmt.Sprintf("%3d%% (%v%% dl, %v/%v ours)", wheelChance(c, place), c.wiw[place], local.wheeled, local.seen)
+}
+
+// List the cards we have the most of our own wheel data for
+func wheelStatsReport() string {
+	uuids := make([]string, 0, len(wheelStats))
+	totals := make(map[string]wheelCount)
+	for uuid, counts := range wheelStats {
+		var t wheelCount
+		for _, wc := range counts {
+			t.seen += wc.seen
+			t.wheeled += wc.wheeled
+		}
+		totals[uuid] = t
+		uuids = append(uuids, uuid)
+	}
+	if len(uuids) == 0 {
+		return fmt.Sprintf("No packs have come back around in the drafts in '%v' yet\n", Config["draft_log_dir"])
+	}
+	sort.Slice(uuids, func(i, j int) bool {
+		if totals[uuids[i]].seen != totals[uuids[j]].seen {
+			return totals[uuids[i]].seen > totals[uuids[j]].seen
+		}
+		return getCardNameFromUUID(uuids[i]) < getCardNameFromUUID(uuids[j])
+	})
+	s := "==========================       OUR WHEEL STATS        ==========================\n"
+	for _, uuid := range uuids {
+		c := cardCollection[uuid]
+		s += fmt.Sprintf("%-30v %3d/%-3d wheeled |", getCardNameFromUUID(uuid), totals[uuid].wheeled, totals[uuid].seen)
+		for place, wc := range wheelStats[uuid] {
+			if wc.seen > 0 {
+				s += fmt.Sprintf(" pick %v: %v/%v (dl %v%%)", place-8, wc.wheeled, wc.seen, c.wiw[place])
+			}
+		}
+		s += "\n"
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+// Test cases for our own wheel statistics
+
+package main
+
+import (
+	"testing"
+)
+
+func TestWheelStats(t *testing.T) {
+	uuids := testDraftSetup(t, 17)
+	wheelStats = make(map[string]*[18]wheelCount)
+	draftPackEvent(testDraftPackMessage(uuids))
+	draftCardPickedEvent(testDraftPickMessage(uuids[0]))
+	draftPackEvent(testDraftPackMessage(uuids[1:10]))
+
+	// Pick 1 is wheel index 9. Our own pick shouldn't count at all.
+	if wc := localWheelCount(uuids[0], 9); wc.seen != 0 {
+		t.Errorf("our own pick was counted %v times but we expected 0", wc.seen)
+	}
+	if wc := localWheelCount(uuids[1], 9); wc != (wheelCount{seen: 1, wheeled: 1}) {
+		t.Errorf("localWheelCount(%v) == %+v but we expected 1/1", uuids[1], wc)
+	}
+	if wc := localWheelCount(uuids[12], 9); wc != (wheelCount{seen: 1, wheeled: 0}) {
+		t.Errorf("localWheelCount(%v) == %+v but we expected 0/1", uuids[12], wc)
+	}
+
+	// Reading the draft back from its log should give us the same numbers
+	wheelStats = make(map[string]*[18]wheelCount)
+	learnWheelStats(readDraftLogs())
+	if wc := localWheelCount(uuids[1], 9); wc != (wheelCount{seen: 1, wheeled: 1}) {
+		t.Errorf("after learnWheelStats() localWheelCount(%v) == %+v but we expected 1/1", uuids[1], wc)
+	}
+}
+
+func TestWheelChance(t *testing.T) {
+	Config = make(map[string]string)
+	uuid := "00000000-0000-0000-0000-0000000000ff"
+	c := Card{uuid: uuid}
+	c.wiw[9] = 50
+	wheelStats = map[string]*[18]wheelCount{uuid: {9: {seen: 10, wheeled: 10}}}
+	for _, f := range []struct {
+		mode   string
+		weight string
+		place  int
+		want   int
+	}{
+		{"blend", "10", 9, 75},
+		{"blend", "0", 9, 100},
+		{"", "", 9, 75},
+		{"local", "", 9, 100},
+		{"downloaded", "", 9, 50},
+		{"blend", "10", 10, 0},
+	} {
+		Config["wheel_stats"] = f.mode
+		Config["wheel_stats_weight"] = f.weight
+		got := wheelChance(c, f.place)
+		if got != f.want {
+			t.Errorf("wheelChance() with %v/%v at %v == %v but we expected %v", f.mode, f.weight, f.place, got, f.want)
+		}
+	}
+}